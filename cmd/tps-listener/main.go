@@ -2,12 +2,18 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/cflager"
@@ -16,7 +22,14 @@ import (
 	"code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/locket"
+	"code.cloudfoundry.org/tps"
 	"code.cloudfoundry.org/tps/handler"
+	"code.cloudfoundry.org/tps/handler/lrpstats"
+	"code.cloudfoundry.org/tps/handler/metricshistory"
+	"code.cloudfoundry.org/tps/handler/prommetrics"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
+	"code.cloudfoundry.org/tps/logcache_client"
+	"code.cloudfoundry.org/tps/uaa_client"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/cloudfoundry/noaa/consumer"
 	"github.com/hashicorp/consul/api"
@@ -50,6 +63,18 @@ var trafficControllerURL = flag.String(
 	"URL of TrafficController",
 )
 
+var metricsSource = flag.String(
+	"metricsSource",
+	"trafficcontroller",
+	"where to read container metrics from: trafficcontroller or logcache",
+)
+
+var logCacheURL = flag.String(
+	"logCacheURL",
+	"",
+	"URL of Log Cache, used when metricsSource is logcache",
+)
+
 var skipSSLVerification = flag.Bool(
 	"skipSSLVerification",
 	true,
@@ -59,7 +84,37 @@ var skipSSLVerification = flag.Bool(
 var maxInFlightRequests = flag.Int(
 	"maxInFlightRequests",
 	200,
-	"number of requests to handle at a time; any more will receive 503",
+	"number of requests to handle at a time; any more receive 503, or queue when maxQueuedRequests is set",
+)
+
+var maxEventStreams = flag.Int(
+	"maxEventStreams",
+	100,
+	"number of LRP event streams to hold open at a time; they do not count against maxInFlightRequests. Zero disables the limit",
+)
+
+var routeMaxInFlightRequests = flag.String(
+	"routeMaxInFlightRequests",
+	"",
+	"comma separated route=limit pairs bounding the requests handled at a time per route, e.g. BulkLRPStatus=20. BulkLRPStatus also bounds the POST and v2 bulk status routes, which may not be named",
+)
+
+var clientMaxInFlightRequests = flag.Int(
+	"clientMaxInFlightRequests",
+	0,
+	"number of requests to handle at a time per client certificate or remote address; zero disables the limit",
+)
+
+var maxQueuedRequests = flag.Int(
+	"maxQueuedRequests",
+	0,
+	"number of requests that may wait up to maxQueueWait for each concurrency limit before receiving 503; zero rejects requests over a limit at once",
+)
+
+var maxQueueWait = flag.Duration(
+	"maxQueueWait",
+	time.Second,
+	"how long a request may wait for a concurrency limit before receiving 503",
 )
 
 var bbsCACert = flag.String(
@@ -98,6 +153,144 @@ var bulkLRPStatusWorkers = flag.Int(
 	"Max concurrency for fetching bulk lrps",
 )
 
+var lookupCacheTTL = flag.Duration(
+	"lookupCacheTTL",
+	0,
+	"how long to reuse successful BBS lookups for identical requests; concurrent identical lookups are always coalesced",
+)
+
+var metricsHistorySamples = flag.Int(
+	"metricsHistorySamples",
+	60,
+	"number of container metric samples to keep per instance for windowed stats",
+)
+
+var metricsHistoryInterval = flag.Duration(
+	"metricsHistoryInterval",
+	0,
+	"how often to sample the container metrics of apps whose windowed stats were recently requested into the history; zero disables the history",
+)
+
+var metricsHistoryWorkers = flag.Int(
+	"metricsHistoryWorkers",
+	10,
+	"max concurrency for fetching container metrics when sampling the history",
+)
+
+var uaaTokenURL = flag.String(
+	"uaaTokenURL",
+	"",
+	"URL of the UAA token endpoint, used to sample container metrics into the history",
+)
+
+var uaaClientName = flag.String(
+	"uaaClientName",
+	"",
+	"UAA client allowed to read the container metrics of every app",
+)
+
+var uaaClientSecret = flag.String(
+	"uaaClientSecret",
+	"",
+	"secret of uaaClientName",
+)
+
+var uaaSkipCertVerify = flag.Bool(
+	"uaaSkipCertVerify",
+	false,
+	"skip verifying the certificate of uaaTokenURL",
+)
+
+var uaaCACertFile = flag.String(
+	"uaaCACertFile",
+	"",
+	"path to the certificate authority used to verify uaaTokenURL; the system roots are used when empty",
+)
+
+var metricsHistoryMaxLogGuids = flag.Int(
+	"metricsHistoryMaxLogGuids",
+	1000,
+	"maximum number of applications to keep container metric history for; the least recently requested are dropped beyond this",
+)
+
+var metricsHistoryWatchTTL = flag.Duration(
+	"metricsHistoryWatchTTL",
+	15*time.Minute,
+	"how long to keep sampling an application's container metrics after its windowed stats were last requested",
+)
+
+var tokenKeysFile = flag.String(
+	"tokenKeysFile",
+	"",
+	"path to a PEM public key or JSON Web Key Set used to validate bearer tokens on the stats endpoints",
+)
+
+var tokenKeysURL = flag.String(
+	"tokenKeysURL",
+	"",
+	"URL of a JSON Web Key Set used to validate bearer tokens on the stats endpoints",
+)
+
+var tokenKeysRefreshInterval = flag.Duration(
+	"tokenKeysRefreshInterval",
+	5*time.Minute,
+	"minimum time between fetches of tokenKeysURL when a token names an unknown key",
+)
+
+var tokenKeysSkipCertVerify = flag.Bool(
+	"tokenKeysSkipCertVerify",
+	false,
+	"skip verifying the certificate of tokenKeysURL",
+)
+
+var tokenKeysCACertFile = flag.String(
+	"tokenKeysCACertFile",
+	"",
+	"path to the certificate authority used to verify tokenKeysURL; the system roots are used when empty",
+)
+
+var tokenAudience = flag.String(
+	"tokenAudience",
+	"",
+	"audience bearer tokens must be issued for; empty skips the check",
+)
+
+var tokenRequiredScopes = flag.String(
+	"tokenRequiredScopes",
+	"",
+	"comma separated scopes bearer tokens must carry",
+)
+
+var serverCertFile = flag.String(
+	"serverCertFile",
+	"",
+	"path to the certificate the api server presents; enables TLS",
+)
+
+var serverKeyFile = flag.String(
+	"serverKeyFile",
+	"",
+	"path to the private key for serverCertFile",
+)
+
+var serverCACertFile = flag.String(
+	"serverCACertFile",
+	"",
+	"path to the certificate authority used to verify client certificates; when set, clients must present one",
+)
+
+var serverSubjectRoutesFile = flag.String(
+	"serverSubjectRoutesFile",
+	"",
+	"path to a JSON file mapping client certificate common names to the route names they may call",
+)
+
+var prometheusListenAddr = flag.String(
+	"prometheusListenAddr",
+	"",
+	"listening address of the Prometheus /metrics endpoint; empty disables it",
+)
+
 var consulCluster = flag.String(
 	"consulCluster",
 	"",
@@ -115,9 +308,12 @@ func main() {
 
 	logger, reconfigurableSink := cflager.New("tps-listener")
 	initializeDropsonde(logger)
-	noaaClient := consumer.New(*trafficControllerURL, &tls.Config{InsecureSkipVerify: *skipSSLVerification}, nil)
-	defer noaaClient.Close()
-	apiHandler := initializeHandler(logger, noaaClient, *maxInFlightRequests, initializeBBSClient(logger))
+	metricsProvider := initializeMetricsProvider(logger)
+	defer metricsProvider.Close()
+	bbsClient := initializeBBSClient(logger)
+	metricsHistory := initializeMetricsHistory()
+	apiHandler := initializeHandler(logger, metricsProvider, metricsHistory, initializeConcurrencyLimits(logger), bbsClient)
+	apiServer := initializeServer(logger, *listenAddr, apiHandler)
 
 	consulClient, err := consuladapter.NewClientFromUrl(*consulCluster)
 	if err != nil {
@@ -127,10 +323,20 @@ func main() {
 	registrationRunner := initializeRegistrationRunner(logger, consulClient, *listenAddr, clock.NewClock())
 
 	members := grouper.Members{
-		{"api", http_server.New(*listenAddr, apiHandler)},
+		{"api", apiServer},
 		{"registration-runner", registrationRunner},
 	}
 
+	if metricsHistory != nil {
+		members = append(members, grouper.Member{"metrics-history-sampler", initializeMetricsHistorySampler(logger, bbsClient, metricsProvider, metricsHistory)})
+	}
+
+	if *prometheusListenAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", prommetrics.Handler())
+		members = append(members, grouper.Member{"prometheus-server", http_server.New(*prometheusListenAddr, metricsMux)})
+	}
+
 	if dbgAddr := debugserver.DebugAddress(flag.CommandLine); dbgAddr != "" {
 		members = append(grouper.Members{
 			{"debug-server", debugserver.Runner(dbgAddr, reconfigurableSink)},
@@ -160,8 +366,101 @@ func initializeDropsonde(logger lager.Logger) {
 	}
 }
 
-func initializeHandler(logger lager.Logger, noaaClient *consumer.Consumer, maxInFlight int, apiClient bbs.Client) http.Handler {
-	apiHandler, err := handler.New(apiClient, noaaClient, maxInFlight, *bulkLRPStatusWorkers, logger)
+// metricsClient is implemented by every metrics source: the latest container
+// metrics serve the stats endpoints and their envelopes feed the history.
+type metricsClient interface {
+	lrpstats.MetricsProvider
+	metricshistory.EnvelopeSource
+}
+
+func initializeMetricsProvider(logger lager.Logger) metricsClient {
+	switch *metricsSource {
+	case "trafficcontroller":
+		return consumer.New(*trafficControllerURL, &tls.Config{InsecureSkipVerify: *skipSSLVerification}, nil)
+	case "logcache":
+		if *logCacheURL == "" {
+			logger.Fatal("missing-log-cache-url", errors.New("logCacheURL is required when metricsSource is logcache"))
+		}
+		return logcache_client.NewLogCacheClient(*logCacheURL, *skipSSLVerification)
+	default:
+		logger.Fatal("invalid-metrics-source", fmt.Errorf("unknown metrics source %q", *metricsSource))
+	}
+
+	return nil
+}
+
+func initializeConcurrencyLimits(logger lager.Logger) handler.ConcurrencyLimits {
+	routeLimits := map[string]int{}
+	if *routeMaxInFlightRequests != "" {
+		for _, pair := range strings.Split(*routeMaxInFlightRequests, ",") {
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				logger.Fatal("invalid-route-max-in-flight-requests", fmt.Errorf("expected route=limit, got %q", pair))
+			}
+
+			if _, ok := tps.Routes.FindRouteByName(parts[0]); !ok {
+				logger.Fatal("invalid-route-max-in-flight-requests", fmt.Errorf("unknown route %q", parts[0]))
+			}
+
+			if budget := handler.RouteBudget(parts[0]); budget != parts[0] {
+				logger.Fatal("invalid-route-max-in-flight-requests", fmt.Errorf("route %q is limited by %s", parts[0], budget))
+			}
+
+			limit, err := strconv.Atoi(parts[1])
+			if err != nil || limit <= 0 {
+				logger.Fatal("invalid-route-max-in-flight-requests", fmt.Errorf("invalid limit %q for route %q", parts[1], parts[0]))
+			}
+
+			routeLimits[parts[0]] = limit
+		}
+	}
+
+	return handler.ConcurrencyLimits{
+		MaxInFlight:       *maxInFlightRequests,
+		MaxStreams:        *maxEventStreams,
+		RouteMaxInFlight:  routeLimits,
+		ClientMaxInFlight: *clientMaxInFlightRequests,
+		MaxQueued:         *maxQueuedRequests,
+		MaxQueueWait:      *maxQueueWait,
+	}
+}
+
+func initializeMetricsHistory() *metricshistory.History {
+	if *metricsHistoryInterval <= 0 || *metricsHistorySamples <= 0 {
+		return nil
+	}
+
+	return metricshistory.New(clock.NewClock(), *metricsHistorySamples, *metricsHistoryMaxLogGuids, *metricsHistoryWatchTTL)
+}
+
+func initializeMetricsHistorySampler(logger lager.Logger, bbsClient bbs.Client, envelopes metricshistory.EnvelopeSource, history *metricshistory.History) ifrit.Runner {
+	if *uaaTokenURL == "" || *uaaClientName == "" {
+		logger.Fatal("invalid-metrics-history", errors.New("metricsHistoryInterval requires uaaTokenURL, uaaClientName and uaaClientSecret"))
+	}
+
+	if *metricsHistoryWorkers <= 0 {
+		logger.Fatal("invalid-metrics-history", fmt.Errorf("metricsHistoryWorkers must be positive, got %d", *metricsHistoryWorkers))
+	}
+
+	tokens := uaa_client.NewTokenSource(*uaaTokenURL, *uaaClientName, *uaaClientSecret, initializeClientTLSConfig(logger, *uaaSkipCertVerify, *uaaCACertFile), clock.NewClock())
+	return metricshistory.NewSampler(logger, bbsClient, envelopes, tokens, history, clock.NewClock(), *metricsHistoryInterval, *metricsHistoryWorkers)
+}
+
+func initializeHandler(logger lager.Logger, metricsProvider lrpstats.MetricsProvider, history *metricshistory.History, limits handler.ConcurrencyLimits, apiClient bbs.Client) http.Handler {
+	var subjectRoutes handler.SubjectRoutes
+	if *serverSubjectRoutesFile != "" {
+		if *serverCACertFile == "" {
+			logger.Fatal("invalid-subject-routes", errors.New("serverSubjectRoutesFile requires serverCACertFile"))
+		}
+
+		var err error
+		subjectRoutes, err = handler.LoadSubjectRoutes(*serverSubjectRoutesFile)
+		if err != nil {
+			logger.Fatal("failed-loading-subject-routes", err)
+		}
+	}
+
+	apiHandler, err := handler.New(apiClient, metricsProvider, history, initializeTokenValidator(logger), subjectRoutes, limits, *bulkLRPStatusWorkers, *lookupCacheTTL, logger)
 	if err != nil {
 		logger.Fatal("initialize-handler.failed", err)
 	}
@@ -169,6 +468,93 @@ func initializeHandler(logger lager.Logger, noaaClient *consumer.Consumer, maxIn
 	return apiHandler
 }
 
+func initializeServer(logger lager.Logger, listenAddress string, apiHandler http.Handler) ifrit.Runner {
+	if *serverCertFile == "" && *serverKeyFile == "" {
+		if *serverCACertFile != "" {
+			logger.Fatal("invalid-server-tls", errors.New("serverCACertFile requires serverCertFile and serverKeyFile"))
+		}
+		return http_server.New(listenAddress, apiHandler)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(*serverCertFile, *serverKeyFile)
+	if err != nil {
+		logger.Fatal("failed-loading-server-certificate", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if *serverCACertFile != "" {
+		caCert, err := ioutil.ReadFile(*serverCACertFile)
+		if err != nil {
+			logger.Fatal("failed-reading-server-ca-cert", err)
+		}
+
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caCert) {
+			logger.Fatal("invalid-server-ca-cert", errors.New("no certificates found in serverCACertFile"))
+		}
+
+		tlsConfig.ClientCAs = caPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return http_server.NewTLSServer(listenAddress, apiHandler, tlsConfig)
+}
+
+func initializeClientTLSConfig(logger lager.Logger, skipCertVerify bool, caCertFile string) *tls.Config {
+	tlsConfig := &tls.Config{InsecureSkipVerify: skipCertVerify}
+	if caCertFile == "" {
+		return tlsConfig
+	}
+
+	caCert, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		logger.Fatal("failed-reading-ca-cert", err, lager.Data{"path": caCertFile})
+	}
+
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		logger.Fatal("invalid-ca-cert", errors.New("no certificates found"), lager.Data{"path": caCertFile})
+	}
+
+	tlsConfig.RootCAs = caPool
+	return tlsConfig
+}
+
+func initializeTokenValidator(logger lager.Logger) tokenauth.Validator {
+	var keySet tokenauth.KeySet
+	switch {
+	case *tokenKeysFile != "" && *tokenKeysURL != "":
+		logger.Fatal("invalid-token-keys", errors.New("only one of tokenKeysFile and tokenKeysURL may be set"))
+	case *tokenKeysFile != "":
+		var err error
+		keySet, err = tokenauth.LoadKeySetFile(*tokenKeysFile)
+		if err != nil {
+			logger.Fatal("failed-loading-token-keys", err)
+		}
+	case *tokenKeysURL != "":
+		httpClient := &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: initializeClientTLSConfig(logger, *tokenKeysSkipCertVerify, *tokenKeysCACertFile),
+			},
+		}
+		keySet = tokenauth.NewJWKSKeySet(*tokenKeysURL, httpClient, clock.NewClock(), *tokenKeysRefreshInterval)
+	default:
+		return nil
+	}
+
+	var scopes []string
+	if *tokenRequiredScopes != "" {
+		scopes = strings.Split(*tokenRequiredScopes, ",")
+	}
+
+	return tokenauth.NewValidator(keySet, *tokenAudience, scopes, clock.NewClock())
+}
+
 func initializeBBSClient(logger lager.Logger) bbs.Client {
 	bbsURL, err := url.Parse(*bbsAddress)
 	if err != nil {
@@ -1,13 +1,23 @@
 package main_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math/big"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/cloudfoundry/sonde-go/events"
@@ -91,6 +101,97 @@ var _ = Describe("TPS-Listener", func() {
 		})
 	})
 
+	Describe("serving TLS", func() {
+		var (
+			certDir    string
+			ca         *testCA
+			caPool     *x509.CertPool
+			statusURL  string
+			summaryURL string
+		)
+
+		tlsClient := func(certificates ...tls.Certificate) *http.Client {
+			return &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{
+						RootCAs:      caPool,
+						Certificates: certificates,
+					},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			var err error
+			certDir, err = ioutil.TempDir("", "tps-listener-tls")
+			Expect(err).NotTo(HaveOccurred())
+
+			ca = newTestCA()
+			caPool = x509.NewCertPool()
+			caPool.AddCert(ca.cert)
+
+			serverCert, serverKey := ca.issuePEM("tps", net.ParseIP("127.0.0.1"))
+			subjectRoutes, err := json.Marshal(map[string][]string{"cloud-controller": {tps.LRPStatus}})
+			Expect(err).NotTo(HaveOccurred())
+
+			runner.Command.Args = append(runner.Command.Args,
+				"-serverCertFile", writeTestFile(certDir, "server.crt", serverCert),
+				"-serverKeyFile", writeTestFile(certDir, "server.key", serverKey),
+				"-serverCACertFile", writeTestFile(certDir, "ca.crt", ca.certPEM),
+				"-serverSubjectRoutesFile", writeTestFile(certDir, "subject-routes.json", subjectRoutes),
+			)
+
+			fakeBBS.RouteToHandler("POST", "/v1/actual_lrp_groups/list_by_process_guid",
+				ghttp.RespondWithProto(200, &models.ActualLRPGroupsResponse{}),
+			)
+
+			statusURL = fmt.Sprintf("https://%s/v1/actual_lrps/some-process-guid", listenerAddr)
+			summaryURL = fmt.Sprintf("https://%s/v1/desired_lrps/some-process-guid/summary", listenerAddr)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(certDir)
+		})
+
+		It("fails the TLS handshake of plaintext requests", func() {
+			response, err := httpClient.Get(fmt.Sprintf("http://%s/v1/actual_lrps/some-process-guid", listenerAddr))
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+
+			Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(ContainSubstring("Client sent an HTTP request to an HTTPS server"))
+			Expect(fakeBBS.ReceivedRequests()).To(BeEmpty())
+		})
+
+		It("rejects clients without a certificate", func() {
+			_, err := tlsClient().Get(statusURL)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects clients with a certificate from another authority", func() {
+			_, err := tlsClient(newTestCA().issue("cloud-controller")).Get(statusURL)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("serves clients with a valid certificate", func() {
+			response, err := tlsClient(ca.issue("cloud-controller")).Get(statusURL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("forbids routes the client's subject may not call", func() {
+			response, err := tlsClient(ca.issue("cloud-controller")).Get(summaryURL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusForbidden))
+
+			response, err = tlsClient(ca.issue("dashboard")).Get(statusURL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusForbidden))
+		})
+	})
+
 	Describe("GET /v1/actual_lrps/:guid", func() {
 		Context("when the bbs is running", func() {
 			JustBeforeEach(func() {
@@ -543,3 +644,75 @@ func marshalMessage(message *events.Envelope) []byte {
 
 	return data
 }
+
+type testCA struct {
+	cert    *x509.Certificate
+	certPEM []byte
+	key     *ecdsa.PrivateKey
+}
+
+func newTestCA() *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tps-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &testCA{
+		cert:    cert,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		key:     key,
+	}
+}
+
+// issuePEM returns a certificate for commonName, usable by servers at ips
+// and by clients, and its key, both PEM encoded.
+func (ca *testCA) issuePEM(commonName string, ips ...net.IP) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) issue(commonName string) tls.Certificate {
+	certPEM, keyPEM := ca.issuePEM(commonName)
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	Expect(err).NotTo(HaveOccurred())
+	return certificate
+}
+
+func writeTestFile(dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	Expect(ioutil.WriteFile(path, data, 0600)).To(Succeed())
+	return path
+}
@@ -16,6 +16,10 @@ import (
 	"code.cloudfoundry.org/tps"
 	"code.cloudfoundry.org/tps/cc_client"
 	"code.cloudfoundry.org/tps/watcher"
+	"code.cloudfoundry.org/tps/watcher/dedupe"
+	"code.cloudfoundry.org/tps/watcher/retryqueue"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+	"code.cloudfoundry.org/tps/watcher/transitions"
 	"github.com/cloudfoundry/dropsonde"
 	"github.com/nu7hatch/gouuid"
 	"github.com/tedsuo/ifrit"
@@ -113,6 +117,90 @@ var eventHandlingWorkers = flag.Int(
 	"Max concurrency for handling lrp events",
 )
 
+var transitionURL = flag.String(
+	"transitionURL",
+	"",
+	"URL to POST app instance state transitions to, such as STARTING to RUNNING. If empty, only crashes are reported",
+)
+
+var sinksConfigFile = flag.String(
+	"sinksConfigFile",
+	"",
+	"path to a JSON array of additional notification sinks (webhook, file or syslog) that receive crashes and transitions",
+)
+
+var domainRoutesFile = flag.String(
+	"domainRoutesFile",
+	"",
+	"path to a JSON array of {domain, reason, sinks} routes selecting which LRP domains (or path.Match patterns) to watch, the crash reason to report for each, and the sinks to notify. Routes other than cf-apps must name their sinks and may not name cc. Defaults to the cf-apps domain reported as CRASHED to every sink",
+)
+
+var crashReconcileLookback = flag.Duration(
+	"crashReconcileLookback",
+	watcher.DefaultReconcileLookback,
+	"on startup, how far back to look for crashes that were not reported while no watcher was subscribed to BBS events",
+)
+
+var crashRetryJournal = flag.String(
+	"crashRetryJournal",
+	"memory",
+	"where to keep crash reports that failed to reach Cloud Controller until they are redelivered: 'memory', 'file' to survive restarts, or 'consul' to also hand them to the next elected watcher",
+)
+
+var crashRetryJournalDir = flag.String(
+	"crashRetryJournalDir",
+	"",
+	"directory for the crash retry journal when crashRetryJournal is 'file'",
+)
+
+var crashRetryWorkers = flag.Int(
+	"crashRetryWorkers",
+	retryqueue.DefaultWorkers,
+	"max concurrency for redelivering crash reports",
+)
+
+var crashRetryMaxEntries = flag.Int(
+	"crashRetryMaxEntries",
+	retryqueue.DefaultMaxEntries,
+	"maximum number of crash reports awaiting redelivery; the oldest are dropped beyond this",
+)
+
+var crashRetryMaxAge = flag.Duration(
+	"crashRetryMaxAge",
+	retryqueue.DefaultMaxAge,
+	"how long to keep retrying a crash report before dropping it",
+)
+
+var crashRetryInitialBackoff = flag.Duration(
+	"crashRetryInitialBackoff",
+	retryqueue.DefaultInitialBackoff,
+	"delay before the first redelivery of a crash report, doubled after every failed attempt",
+)
+
+var crashRetryMaxBackoff = flag.Duration(
+	"crashRetryMaxBackoff",
+	retryqueue.DefaultMaxBackoff,
+	"maximum delay between redeliveries of a crash report",
+)
+
+var crashDedupeStore = flag.String(
+	"crashDedupeStore",
+	"memory",
+	"where to remember delivered crash reports so duplicates are skipped: 'memory', or 'consul' to share them with the next elected watcher",
+)
+
+var crashDedupeTTL = flag.Duration(
+	"crashDedupeTTL",
+	dedupe.DefaultTTL,
+	"how long a delivered crash report is remembered; should exceed crashRetryMaxAge",
+)
+
+var crashDedupeMaxEntries = flag.Int(
+	"crashDedupeMaxEntries",
+	dedupe.DefaultMaxEntries,
+	"maximum number of delivered crash reports to remember",
+)
+
 const (
 	dropsondeOrigin = "tps_watcher"
 )
@@ -125,16 +213,22 @@ func main() {
 	logger, reconfigurableSink := cflager.New("tps-watcher")
 	initializeDropsonde(logger)
 
-	lockMaintainer := initializeLockMaintainer(logger)
+	consulClient := initializeConsulClient(logger)
+	lockMaintainer := initializeLockMaintainer(logger, consulClient)
 
-	ccClient := cc_client.NewCcClient(*ccBaseURL, *ccUsername, *ccPassword, *skipCertVerify)
+	ccClient := initializeCcClient(logger, consulClient)
+	retryQueue := initializeRetryQueue(logger, consulClient, ccClient)
+	notifier := initializeNotifier(logger, ccClient, retryQueue)
+	domainRoutes := initializeDomainRoutes(logger, notifier.Names())
 
 	watcher := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 		w, err := watcher.NewWatcher(logger,
 			*eventHandlingWorkers,
 			watcher.DefaultRetryPauseInterval,
-			initializeBBSClient(logger), ccClient)
+			*crashReconcileLookback,
+			domainRoutes,
+			initializeBBSClient(logger), notifier, clock.NewClock())
 
 		if err != nil {
 			return err
@@ -145,6 +239,8 @@ func main() {
 
 	members := grouper.Members{
 		{"lock-maintainer", lockMaintainer},
+		{"retry-queue", retryQueue},
+		{"notifier", notifier},
 		{"watcher", watcher},
 	}
 
@@ -177,17 +273,17 @@ func initializeDropsonde(logger lager.Logger) {
 	}
 }
 
-func initializeServiceClient(logger lager.Logger) tps.ServiceClient {
+func initializeConsulClient(logger lager.Logger) consuladapter.Client {
 	consulClient, err := consuladapter.NewClientFromUrl(*consulCluster)
 	if err != nil {
 		logger.Fatal("new-client-failed", err)
 	}
 
-	return tps.NewServiceClient(consulClient, clock.NewClock())
+	return consulClient
 }
 
-func initializeLockMaintainer(logger lager.Logger) ifrit.Runner {
-	serviceClient := initializeServiceClient(logger)
+func initializeLockMaintainer(logger lager.Logger, consulClient consuladapter.Client) ifrit.Runner {
+	serviceClient := tps.NewServiceClient(consulClient, clock.NewClock())
 
 	uuid, err := uuid.NewV4()
 	if err != nil {
@@ -197,6 +293,117 @@ func initializeLockMaintainer(logger lager.Logger) ifrit.Runner {
 	return serviceClient.NewTPSWatcherLockRunner(logger, uuid.String(), *lockRetryInterval, *lockTTL)
 }
 
+func initializeCcClient(logger lager.Logger, consulClient consuladapter.Client) cc_client.CcClient {
+	ccClient := cc_client.NewCcClient(*ccBaseURL, *ccUsername, *ccPassword, *skipCertVerify)
+
+	var store dedupe.Store
+	switch *crashDedupeStore {
+	case "memory":
+		store = dedupe.NewMemoryStore(clock.NewClock(), *crashDedupeTTL, *crashDedupeMaxEntries)
+	case "consul":
+		store = dedupe.NewConsulStore(consulClient, clock.NewClock(), *crashDedupeTTL, *crashDedupeMaxEntries, dedupe.DefaultPruneInterval)
+	default:
+		logger.Fatal("invalid-crash-dedupe-store", fmt.Errorf("unknown crashDedupeStore %q", *crashDedupeStore))
+	}
+
+	return dedupe.NewCcClient(ccClient, store)
+}
+
+func initializeNotifier(logger lager.Logger, ccClient cc_client.CcClient, retryQueue retryqueue.Queue) *sinks.Fanout {
+	targets := []sinks.Target{{
+		Name:        sinks.CCSinkName,
+		Sink:        sinks.NewCCSink(ccClient, retryQueue),
+		Kinds:       []string{sinks.CrashKind},
+		Attempts:    1,
+		Workers:     *eventHandlingWorkers,
+		Undelivered: sinks.RetryUndelivered(retryQueue),
+	}}
+
+	if *transitionURL != "" {
+		targets = append(targets, sinks.Target{
+			Name:     sinks.TransitionSinkName,
+			Sink:     sinks.NewTransitionSink(transitions.NewClient(*transitionURL, *skipCertVerify)),
+			Kinds:    []string{sinks.TransitionKind},
+			Attempts: 1,
+		})
+	}
+
+	if *sinksConfigFile != "" {
+		configs, err := sinks.LoadConfig(*sinksConfigFile)
+		if err != nil {
+			logger.Fatal("failed-to-load-sinks-config", err)
+		}
+
+		for _, config := range configs {
+			target, err := sinks.NewTarget(config, clock.NewClock())
+			if err != nil {
+				logger.Fatal("failed-to-initialize-sink", err)
+			}
+			targets = append(targets, target)
+		}
+	}
+
+	return sinks.NewFanout(targets...)
+}
+
+func initializeDomainRoutes(logger lager.Logger, sinkNames []string) watcher.DomainRoutes {
+	if *domainRoutesFile == "" {
+		return watcher.DefaultDomainRoutes
+	}
+
+	domainRoutes, err := watcher.LoadDomainRoutes(*domainRoutesFile)
+	if err != nil {
+		logger.Fatal("failed-to-load-domain-routes", err)
+	}
+
+	err = domainRoutes.Validate(sinkNames)
+	if err != nil {
+		logger.Fatal("invalid-domain-routes", err)
+	}
+
+	return domainRoutes
+}
+
+func initializeRetryQueue(logger lager.Logger, consulClient consuladapter.Client, ccClient cc_client.CcClient) *retryqueue.RetryQueue {
+	if *crashRetryMaxEntries <= 0 {
+		logger.Fatal("invalid-crash-retry-max-entries", fmt.Errorf("crashRetryMaxEntries must be positive, got %d", *crashRetryMaxEntries))
+	}
+
+	if *crashRetryWorkers <= 0 {
+		logger.Fatal("invalid-crash-retry-workers", fmt.Errorf("crashRetryWorkers must be positive, got %d", *crashRetryWorkers))
+	}
+
+	var journal retryqueue.Journal
+	switch *crashRetryJournal {
+	case "memory":
+		journal = retryqueue.NewMemoryJournal()
+	case "file":
+		if *crashRetryJournalDir == "" {
+			logger.Fatal("invalid-crash-retry-journal", fmt.Errorf("crashRetryJournalDir is required when crashRetryJournal is %q", "file"))
+		}
+
+		var err error
+		journal, err = retryqueue.NewFileJournal(logger, *crashRetryJournalDir)
+		if err != nil {
+			logger.Fatal("failed-to-open-crash-retry-journal", err)
+		}
+	case "consul":
+		journal = retryqueue.NewConsulJournal(logger, consulClient)
+	default:
+		logger.Fatal("invalid-crash-retry-journal", fmt.Errorf("unknown crashRetryJournal %q", *crashRetryJournal))
+	}
+
+	return retryqueue.New(logger, journal, ccClient, clock.NewClock(), retryqueue.Config{
+		MaxEntries:     *crashRetryMaxEntries,
+		MaxAge:         *crashRetryMaxAge,
+		InitialBackoff: *crashRetryInitialBackoff,
+		MaxBackoff:     *crashRetryMaxBackoff,
+		PollInterval:   retryqueue.DefaultPollInterval,
+		Workers:        *crashRetryWorkers,
+		MaxPerPoll:     retryqueue.DefaultMaxPerPoll,
+	})
+}
+
 func initializeBBSClient(logger lager.Logger) bbs.Client {
 	bbsURL, err := url.Parse(*bbsAddress)
 	if err != nil {
@@ -0,0 +1,111 @@
+package bulklrpstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/handler/bulklrpstatus"
+	"code.cloudfoundry.org/tps/handler/lrpstats"
+	"code.cloudfoundry.org/tps/handler/prommetrics"
+	"code.cloudfoundry.org/tps/handler/requestid"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
+	"code.cloudfoundry.org/workpool"
+)
+
+type handler struct {
+	bbsClient                bbs.Client
+	metricsProvider          lrpstats.MetricsProvider
+	validator                tokenauth.Validator
+	clock                    clock.Clock
+	logger                   lager.Logger
+	bulkLRPStatsWorkPoolSize int
+}
+
+func NewHandler(bbsClient bbs.Client, metricsProvider lrpstats.MetricsProvider, validator tokenauth.Validator, clk clock.Clock, bulkLRPStatsWorkPoolSize int, logger lager.Logger) http.Handler {
+	return &handler{
+		bbsClient:                bbsClient,
+		metricsProvider:          metricsProvider,
+		validator:                validator,
+		clock:                    clk,
+		bulkLRPStatsWorkPoolSize: bulkLRPStatsWorkPoolSize,
+		logger:                   logger,
+	}
+}
+
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := handler.logger.Session("bulk-lrp-stats", requestid.Data(r, nil))
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if handler.validator != nil {
+		err := handler.validator.Validate(authorization)
+		if err != nil {
+			logger.Error("invalid-token", err)
+			w.WriteHeader(tokenauth.StatusCode(err))
+			return
+		}
+	}
+
+	guidParameter := r.FormValue("guids")
+	if !bulklrpstatus.ProcessGuidsPattern.Match([]byte(guidParameter)) {
+		logger.Error("failed-parsing-guids", nil, lager.Data{"guid-parameter": guidParameter})
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	guids := strings.Split(guidParameter, ",")
+	works := []func(){}
+
+	statsBundle := make(map[string][]lrpstats.LRPInstance)
+	statsLock := sync.Mutex{}
+
+	for _, processGuid := range guids {
+		works = append(works, handler.getStatsForLRPWorkFunction(logger, processGuid, authorization, &statsLock, statsBundle))
+	}
+
+	works = prommetrics.QueuedWork("bulk-lrp-stats", works)
+	throttler, err := workpool.NewThrottler(handler.bulkLRPStatsWorkPoolSize, works)
+	if err != nil {
+		prommetrics.DiscardQueuedWork("bulk-lrp-stats", works)
+		logger.Error("failed-constructing-throttler", err, lager.Data{"max-workers": handler.bulkLRPStatsWorkPoolSize, "num-works": len(works)})
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	throttler.Work()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(statsBundle)
+	if err != nil {
+		logger.Error("stream-response-failed", err, nil)
+	}
+}
+
+func (handler *handler) getStatsForLRPWorkFunction(logger lager.Logger, processGuid, authorization string, statsLock *sync.Mutex, statsBundle map[string][]lrpstats.LRPInstance) func() {
+	return func() {
+		logger := logger.Session("fetching-lrp-stats", lager.Data{"process-guid": processGuid})
+		logger.Info("start")
+		defer logger.Info("complete")
+
+		instances, err := lrpstats.InstancesWithStats(logger, handler.bbsClient, handler.metricsProvider, handler.clock, processGuid, authorization)
+		if err != nil {
+			logger.Error("fetching-lrp-stats-failed", err)
+			return
+		}
+
+		statsLock.Lock()
+		statsBundle[processGuid] = instances
+		statsLock.Unlock()
+	}
+}
@@ -0,0 +1,13 @@
+package bulklrpstats_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBulkLRPStats(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bulk LRP Stats Suite")
+}
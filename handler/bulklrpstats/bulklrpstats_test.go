@@ -0,0 +1,231 @@
+package bulklrpstats_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/nsync/recipebuilder"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/bulklrpstats"
+	"code.cloudfoundry.org/tps/handler/lrpstats/fakes"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
+	tokenauthfakes "code.cloudfoundry.org/tps/handler/tokenauth/fakes"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Bulk Stats", func() {
+	const authorization = "something good"
+	const guid1 = "my-guid1"
+	const guid2 = "my-guid2"
+	const logGuid1 = "log-guid1"
+	const logGuid2 = "log-guid2"
+
+	var (
+		handler         http.Handler
+		response        *httptest.ResponseRecorder
+		request         *http.Request
+		metricsProvider *fakes.FakeMetricsProvider
+		bbsClient       *fake_bbs.FakeClient
+		logger          *lagertest.TestLogger
+		fakeClock       *fakeclock.FakeClock
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		bbsClient = new(fake_bbs.FakeClient)
+		metricsProvider = &fakes.FakeMetricsProvider{}
+		logger = lagertest.NewTestLogger("test")
+		fakeClock = fakeclock.NewFakeClock(time.Date(2008, 8, 8, 8, 8, 8, 8, time.UTC))
+		handler = bulklrpstats.NewHandler(bbsClient, metricsProvider, nil, fakeClock, 15, logger)
+		response = httptest.NewRecorder()
+		request, err = http.NewRequest("GET", "/v1/bulk_actual_lrp_stats", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		handler.ServeHTTP(response, request)
+	})
+
+	Describe("Validation", func() {
+		It("fails with a missing authorization header", func() {
+			Expect(response.Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		Context("with an authorization header", func() {
+			BeforeEach(func() {
+				request.Header.Set("Authorization", authorization)
+			})
+
+			Context("with no process guids", func() {
+				It("fails with missing process guids", func() {
+					Expect(response.Code).To(Equal(http.StatusBadRequest))
+				})
+			})
+
+			Context("with malformed process guids", func() {
+				BeforeEach(func() {
+					query := request.URL.Query()
+					query.Set("guids", fmt.Sprintf("%s,,%s", guid1, guid2))
+					request.URL.RawQuery = query.Encode()
+				})
+
+				It("fails", func() {
+					Expect(response.Code).To(Equal(http.StatusBadRequest))
+				})
+			})
+
+			Context("when a token validator is configured", func() {
+				var validator *tokenauthfakes.FakeValidator
+
+				BeforeEach(func() {
+					validator = &tokenauthfakes.FakeValidator{}
+					handler = bulklrpstats.NewHandler(bbsClient, metricsProvider, validator, fakeClock, 15, logger)
+
+					query := request.URL.Query()
+					query.Set("guids", fmt.Sprintf("%s,%s", guid1, guid2))
+					request.URL.RawQuery = query.Encode()
+				})
+
+				Context("and the token lacks a required scope", func() {
+					BeforeEach(func() {
+						validator.ValidateReturns(tokenauth.ErrInsufficientScope)
+					})
+
+					It("fails with a 403 without contacting the BBS", func() {
+						Expect(validator.ValidateArgsForCall(0)).To(Equal(authorization))
+						Expect(response.Code).To(Equal(http.StatusForbidden))
+						Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(0))
+					})
+				})
+
+				Context("and the token is invalid", func() {
+					BeforeEach(func() {
+						validator.ValidateReturns(tokenauth.ErrInvalidSignature)
+					})
+
+					It("fails with a 401", func() {
+						Expect(response.Code).To(Equal(http.StatusUnauthorized))
+						Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(0))
+					})
+				})
+			})
+		})
+	})
+
+	Describe("retrieves stats for the lrps specified", func() {
+		var netInfo models.ActualLRPNetInfo
+
+		BeforeEach(func() {
+			request.Header.Set("Authorization", authorization)
+
+			query := request.URL.Query()
+			query.Set("guids", fmt.Sprintf("%s,%s", guid1, guid2))
+			request.URL.RawQuery = query.Encode()
+
+			netInfo = models.NewActualLRPNetInfo(
+				"host",
+				models.NewPortMapping(1234, uint32(recipebuilder.DefaultPort)),
+			)
+
+			bbsClient.DesiredLRPByProcessGuidStub = func(logger lager.Logger, processGuid string) (*models.DesiredLRP, error) {
+				switch processGuid {
+				case guid1:
+					return &models.DesiredLRP{ProcessGuid: guid1, LogGuid: logGuid1}, nil
+				case guid2:
+					return &models.DesiredLRP{ProcessGuid: guid2, LogGuid: logGuid2}, nil
+				default:
+					return nil, errors.New("WHAT?")
+				}
+			}
+
+			bbsClient.ActualLRPGroupsByProcessGuidStub = func(logger lager.Logger, processGuid string) ([]*models.ActualLRPGroup, error) {
+				actualLRP := &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey(processGuid, 0, "some-domain"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instanceId", "some-cell"),
+					ActualLRPNetInfo:     netInfo,
+					State:                models.ActualLRPStateRunning,
+					Since:                fakeClock.Now().UnixNano(),
+				}
+				return []*models.ActualLRPGroup{{Instance: actualLRP}}, nil
+			}
+
+			metricsProvider.ContainerMetricsStub = func(logGuid string, token string) ([]*events.ContainerMetric, error) {
+				return []*events.ContainerMetric{
+					{
+						ApplicationId: proto.String(logGuid),
+						InstanceIndex: proto.Int32(0),
+						CpuPercentage: proto.Float64(4),
+						MemoryBytes:   proto.Uint64(1024),
+						DiskBytes:     proto.Uint64(2048),
+					},
+				}, nil
+			}
+		})
+
+		It("returns a map of stats per process guid", func() {
+			stats := make(map[string][]cc_messages.LRPInstance)
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+
+			err := json.Unmarshal(response.Body.Bytes(), &stats)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stats).To(HaveLen(2))
+			for _, guid := range []string{guid1, guid2} {
+				Expect(stats[guid]).To(HaveLen(1))
+				Expect(stats[guid][0].ProcessGuid).To(Equal(guid))
+				Expect(stats[guid][0].Host).To(Equal("host"))
+				Expect(stats[guid][0].Port).To(BeEquivalentTo(1234))
+				Expect(stats[guid][0].Stats).NotTo(BeNil())
+				Expect(stats[guid][0].Stats.CpuPercentage).To(Equal(0.04))
+				Expect(stats[guid][0].Stats.MemoryBytes).To(BeEquivalentTo(1024))
+				Expect(stats[guid][0].Stats.DiskBytes).To(BeEquivalentTo(2048))
+			}
+		})
+
+		It("fetches container metrics with the caller's authorization", func() {
+			Expect(metricsProvider.ContainerMetricsCallCount()).To(Equal(2))
+			_, token := metricsProvider.ContainerMetricsArgsForCall(0)
+			Expect(token).To(Equal(authorization))
+		})
+
+		Context("when fetching one of the desiredLRPs fails", func() {
+			BeforeEach(func() {
+				bbsClient.DesiredLRPByProcessGuidStub = func(logger lager.Logger, processGuid string) (*models.DesiredLRP, error) {
+					if processGuid == guid1 {
+						return &models.DesiredLRP{ProcessGuid: guid1, LogGuid: logGuid1}, nil
+					}
+					return nil, models.ErrResourceNotFound
+				}
+			})
+
+			It("it is excluded from the result and logs the failure", func() {
+				stats := make(map[string][]cc_messages.LRPInstance)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				err := json.Unmarshal(response.Body.Bytes(), &stats)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stats).To(HaveLen(1))
+				Expect(stats[guid2]).To(BeNil())
+				Expect(logger).To(Say("fetching-lrp-stats-failed"))
+			})
+		})
+	})
+})
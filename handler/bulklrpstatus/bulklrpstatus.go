@@ -2,6 +2,8 @@ package bulklrpstatus
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"regexp"
 	"strings"
@@ -12,50 +14,123 @@ import (
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/etag"
 	"code.cloudfoundry.org/tps/handler/lrpstatus"
+	"code.cloudfoundry.org/tps/handler/prommetrics"
+	"code.cloudfoundry.org/tps/handler/requestid"
 	"code.cloudfoundry.org/workpool"
 )
 
-var processGuidPattern = regexp.MustCompile(`^([a-zA-Z0-9_-]+,)*[a-zA-Z0-9_-]+$`)
+const maxRequestBodyBytes = 4 * 1024 * 1024
+
+// ProcessGuidsPattern matches the comma separated process guids the bulk
+// endpoints take in their guids parameter.
+var ProcessGuidsPattern = regexp.MustCompile(`^([a-zA-Z0-9_-]+,)*[a-zA-Z0-9_-]+$`)
+var singleProcessGuidPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+var ErrNoProcessGuids = errors.New("no process guids requested")
+
+type BulkLRPStatusRequest struct {
+	ProcessGuids []string                    `json:"process_guids"`
+	Options      map[string]LRPStatusOptions `json:"options,omitempty"`
+}
+
+type LRPStatusOptions struct {
+	Indices []uint                         `json:"indices,omitempty"`
+	States  []cc_messages.LRPInstanceState `json:"states,omitempty"`
+}
+
+type BulkLRPStatusResponse struct {
+	Instances map[string][]cc_messages.LRPInstance `json:"instances"`
+	Errors    map[string]LRPStatusError            `json:"errors,omitempty"`
+}
+
+type LRPStatusError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type InvalidProcessGuidError struct {
+	ProcessGuid string
+}
+
+func (e InvalidProcessGuidError) Error() string {
+	return "invalid process guid: " + e.ProcessGuid
+}
 
 type handler struct {
 	bbsClient                 bbs.Client
 	clock                     clock.Clock
 	logger                    lager.Logger
 	bulkLRPStatusWorkPoolSize int
+	reportErrors              bool
+}
+
+type statusResult struct {
+	lock      sync.Mutex
+	instances map[string][]cc_messages.LRPInstance
+	errors    map[string]LRPStatusError
 }
 
 func NewHandler(bbsClient bbs.Client, clk clock.Clock, bulkLRPStatusWorkPoolSize int, logger lager.Logger) http.Handler {
 	return &handler{
-		bbsClient: bbsClient,
-		clock:     clk,
+		bbsClient:                 bbsClient,
+		clock:                     clk,
+		bulkLRPStatusWorkPoolSize: bulkLRPStatusWorkPoolSize,
+		logger:                    logger,
+	}
+}
+
+func NewV2Handler(bbsClient bbs.Client, clk clock.Clock, bulkLRPStatusWorkPoolSize int, logger lager.Logger) http.Handler {
+	return &handler{
+		bbsClient:                 bbsClient,
+		clock:                     clk,
 		bulkLRPStatusWorkPoolSize: bulkLRPStatusWorkPoolSize,
-		logger: logger,
+		logger:                    logger,
+		reportErrors:              true,
 	}
 }
 
 func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	logger := handler.logger.Session("bulk-lrp-status")
+	logger := handler.logger.Session("bulk-lrp-status", requestid.Data(r, nil))
 
-	guidParameter := r.FormValue("guids")
-	if !processGuidPattern.Match([]byte(guidParameter)) {
-		logger.Error("failed-parsing-guids", nil, lager.Data{"guid-parameter": guidParameter})
-		w.WriteHeader(http.StatusBadRequest)
-		return
+	var guids []string
+	var options map[string]LRPStatusOptions
+
+	if r.Method == "POST" {
+		var err error
+		guids, options, err = parseRequestBody(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+		if err != nil {
+			logger.Error("failed-parsing-request-body", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		guidParameter := r.FormValue("guids")
+		if !ProcessGuidsPattern.Match([]byte(guidParameter)) {
+			logger.Error("failed-parsing-guids", nil, lager.Data{"guid-parameter": guidParameter})
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		guids = strings.Split(guidParameter, ",")
 	}
 
-	guids := strings.Split(guidParameter, ",")
 	works := []func(){}
 
-	statusBundle := make(map[string][]cc_messages.LRPInstance)
-	statusLock := sync.Mutex{}
+	result := &statusResult{
+		instances: make(map[string][]cc_messages.LRPInstance),
+		errors:    make(map[string]LRPStatusError),
+	}
 
 	for _, processGuid := range guids {
-		works = append(works, handler.getStatusForLRPWorkFunction(logger, processGuid, &statusLock, statusBundle))
+		works = append(works, handler.getStatusForLRPWorkFunction(logger, processGuid, options[processGuid], result))
 	}
 
+	works = prommetrics.QueuedWork("bulk-lrp-status", works)
 	throttler, err := workpool.NewThrottler(handler.bulkLRPStatusWorkPoolSize, works)
 	if err != nil {
+		prommetrics.DiscardQueuedWork("bulk-lrp-status", works)
 		logger.Error("failed-constructing-throttler", err, lager.Data{"max-workers": handler.bulkLRPStatusWorkPoolSize, "num-works": len(works)})
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -63,23 +138,66 @@ func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	throttler.Work()
 
-	w.WriteHeader(http.StatusOK)
+	if len(result.errors) == 0 {
+		var fingerprint interface{} = withoutUptimes(result.instances)
+		if handler.reportErrors {
+			fingerprint = BulkLRPStatusResponse{Instances: withoutUptimes(result.instances)}
+		}
+
+		notModified, err := etag.CheckNotModified(w, r, fingerprint)
+		if err != nil {
+			logger.Error("failed-computing-etag", err)
+		}
+		if notModified {
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
-	err = json.NewEncoder(w).Encode(statusBundle)
+	if !handler.reportErrors {
+		w.WriteHeader(http.StatusOK)
+		err = json.NewEncoder(w).Encode(result.instances)
+		if err != nil {
+			logger.Error("stream-response-failed", err, nil)
+		}
+		return
+	}
+
+	switch {
+	case len(result.errors) == 0:
+		w.WriteHeader(http.StatusOK)
+	case len(result.instances) == 0:
+		w.WriteHeader(http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+
+	err = json.NewEncoder(w).Encode(BulkLRPStatusResponse{
+		Instances: result.instances,
+		Errors:    result.errors,
+	})
 	if err != nil {
 		logger.Error("stream-response-failed", err, nil)
 	}
 }
 
-func (handler *handler) getStatusForLRPWorkFunction(logger lager.Logger, processGuid string, statusLock *sync.Mutex, statusBundle map[string][]cc_messages.LRPInstance) func() {
+func (handler *handler) getStatusForLRPWorkFunction(logger lager.Logger, processGuid string, options LRPStatusOptions, result *statusResult) func() {
 	return func() {
-		logger = logger.Session("fetching-actual-lrps-info", lager.Data{"process-guid": processGuid})
+		logger := logger.Session("fetching-actual-lrps-info", lager.Data{"process-guid": processGuid})
 		logger.Info("start")
 		defer logger.Info("complete")
 		actualLRPGroups, err := handler.bbsClient.ActualLRPGroupsByProcessGuid(logger, processGuid)
 		if err != nil {
 			logger.Error("fetching-actual-lrps-info-failed", err)
+
+			bbsErr := models.ConvertError(err)
+			result.lock.Lock()
+			result.errors[processGuid] = LRPStatusError{
+				Type:    bbsErr.Type.String(),
+				Message: bbsErr.Message,
+			}
+			result.lock.Unlock()
 			return
 		}
 
@@ -89,9 +207,89 @@ func (handler *handler) getStatusForLRPWorkFunction(logger lager.Logger, process
 			},
 			handler.clock,
 		)
+		instances = options.filter(instances)
+
+		result.lock.Lock()
+		result.instances[processGuid] = instances
+		result.lock.Unlock()
+	}
+}
+
+func withoutUptimes(bundle map[string][]cc_messages.LRPInstance) map[string][]cc_messages.LRPInstance {
+	stripped := make(map[string][]cc_messages.LRPInstance, len(bundle))
+	for guid, instances := range bundle {
+		stripped[guid] = etag.WithoutUptime(instances)
+	}
+
+	return stripped
+}
+
+func parseRequestBody(body io.Reader) ([]string, map[string]LRPStatusOptions, error) {
+	var request BulkLRPStatusRequest
+	err := json.NewDecoder(body).Decode(&request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(request.ProcessGuids) == 0 {
+		return nil, nil, ErrNoProcessGuids
+	}
+
+	guids := make([]string, 0, len(request.ProcessGuids))
+	seen := make(map[string]struct{}, len(request.ProcessGuids))
+	for _, guid := range request.ProcessGuids {
+		if !singleProcessGuidPattern.MatchString(guid) {
+			return nil, nil, InvalidProcessGuidError{ProcessGuid: guid}
+		}
+
+		if _, ok := seen[guid]; ok {
+			continue
+		}
 
-		statusLock.Lock()
-		statusBundle[processGuid] = instances
-		statusLock.Unlock()
+		seen[guid] = struct{}{}
+		guids = append(guids, guid)
 	}
+
+	return guids, request.Options, nil
+}
+
+func (options LRPStatusOptions) filter(instances []cc_messages.LRPInstance) []cc_messages.LRPInstance {
+	if len(options.Indices) == 0 && len(options.States) == 0 {
+		return instances
+	}
+
+	filtered := []cc_messages.LRPInstance{}
+	for _, instance := range instances {
+		if len(options.Indices) > 0 && !containsIndex(options.Indices, instance.Index) {
+			continue
+		}
+
+		if len(options.States) > 0 && !containsState(options.States, instance.State) {
+			continue
+		}
+
+		filtered = append(filtered, instance)
+	}
+
+	return filtered
+}
+
+func containsIndex(indices []uint, index uint) bool {
+	for _, i := range indices {
+		if i == index {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsState(states []cc_messages.LRPInstanceState, state cc_messages.LRPInstanceState) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+
+	return false
 }
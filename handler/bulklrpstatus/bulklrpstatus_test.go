@@ -1,6 +1,7 @@
 package bulklrpstatus_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -170,6 +171,25 @@ var _ = Describe("Bulk Status", func() {
 			})
 		})
 
+		It("sets an ETag", func() {
+			Expect(response.Header().Get("ETag")).NotTo(BeEmpty())
+		})
+
+		Context("when If-None-Match matches the current state", func() {
+			BeforeEach(func() {
+				preflight := httptest.NewRecorder()
+				handler.ServeHTTP(preflight, request)
+
+				fakeClock.Increment(time.Minute)
+				request.Header.Set("If-None-Match", preflight.Header().Get("ETag"))
+			})
+
+			It("responds with 304 Not Modified", func() {
+				Expect(response.Code).To(Equal(http.StatusNotModified))
+				Expect(response.Body.Len()).To(BeZero())
+			})
+		})
+
 		Context("when fetching one of the actualLRPs fails", func() {
 			BeforeEach(func() {
 				bbsClient.ActualLRPGroupsByProcessGuidStub = func(logger lager.Logger, processGuid string) ([]*models.ActualLRPGroup, error) {
@@ -213,4 +233,197 @@ var _ = Describe("Bulk Status", func() {
 			})
 		})
 	})
+
+	Describe("POST requests", func() {
+		var body interface{}
+
+		BeforeEach(func() {
+			bbsClient.ActualLRPGroupsByProcessGuidStub = func(logger lager.Logger, processGuid string) ([]*models.ActualLRPGroup, error) {
+				return []*models.ActualLRPGroup{
+					{Instance: &models.ActualLRP{
+						ActualLRPKey:         models.NewActualLRPKey(processGuid, 0, "some-domain"),
+						ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-0", "some-cell"),
+						State:                models.ActualLRPStateRunning,
+					}},
+					{Instance: &models.ActualLRP{
+						ActualLRPKey:         models.NewActualLRPKey(processGuid, 1, "some-domain"),
+						ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-1", "some-cell"),
+						State:                models.ActualLRPStateCrashed,
+					}},
+				}, nil
+			}
+		})
+
+		JustBeforeEach(func() {
+			// the outer JustBeforeEach has already served the GET request
+			response = httptest.NewRecorder()
+
+			var reader *bytes.Reader
+			if raw, ok := body.(string); ok {
+				reader = bytes.NewReader([]byte(raw))
+			} else {
+				payload, err := json.Marshal(body)
+				Expect(err).NotTo(HaveOccurred())
+				reader = bytes.NewReader(payload)
+			}
+
+			var err error
+			request, err = http.NewRequest("POST", "/v1/bulk_actual_lrp_status", reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			handler.ServeHTTP(response, request)
+		})
+
+		Context("with a list of process guids", func() {
+			BeforeEach(func() {
+				body = bulklrpstatus.BulkLRPStatusRequest{
+					ProcessGuids: []string{guid1, guid2, guid1},
+				}
+			})
+
+			It("returns the status of each requested process guid once", func() {
+				status := make(map[string][]cc_messages.LRPInstance)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				err := json.Unmarshal(response.Body.Bytes(), &status)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(status).To(HaveLen(2))
+				Expect(status[guid1]).To(HaveLen(2))
+				Expect(status[guid2]).To(HaveLen(2))
+			})
+		})
+
+		Context("with per-guid options", func() {
+			BeforeEach(func() {
+				body = bulklrpstatus.BulkLRPStatusRequest{
+					ProcessGuids: []string{guid1, guid2},
+					Options: map[string]bulklrpstatus.LRPStatusOptions{
+						guid1: {Indices: []uint{1}},
+						guid2: {States: []cc_messages.LRPInstanceState{cc_messages.LRPInstanceStateRunning}},
+					},
+				}
+			})
+
+			It("filters the instances of each process guid", func() {
+				status := make(map[string][]cc_messages.LRPInstance)
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				err := json.Unmarshal(response.Body.Bytes(), &status)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(status[guid1]).To(HaveLen(1))
+				Expect(status[guid1][0].InstanceGuid).To(Equal("instance-1"))
+				Expect(status[guid2]).To(HaveLen(1))
+				Expect(status[guid2][0].InstanceGuid).To(Equal("instance-0"))
+			})
+		})
+
+		Context("with no process guids", func() {
+			BeforeEach(func() {
+				body = bulklrpstatus.BulkLRPStatusRequest{}
+			})
+
+			It("fails", func() {
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+
+		Context("with a malformed process guid", func() {
+			BeforeEach(func() {
+				body = bulklrpstatus.BulkLRPStatusRequest{
+					ProcessGuids: []string{guid1, "not,valid"},
+				}
+			})
+
+			It("fails", func() {
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+				Expect(logger).To(Say("failed-parsing-request-body"))
+			})
+		})
+
+		Context("with invalid JSON", func() {
+			BeforeEach(func() {
+				body = "{not-json"
+			})
+
+			It("fails", func() {
+				Expect(response.Code).To(Equal(http.StatusBadRequest))
+			})
+		})
+	})
+
+	Describe("v2 responses", func() {
+		BeforeEach(func() {
+			handler = bulklrpstatus.NewV2Handler(bbsClient, fakeClock, 15, logger)
+
+			query := request.URL.Query()
+			query.Set("guids", fmt.Sprintf("%s,%s", guid1, guid2))
+			request.URL.RawQuery = query.Encode()
+		})
+
+		Context("when every lookup succeeds", func() {
+			BeforeEach(func() {
+				bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{}, nil)
+			})
+
+			It("responds with a 200 and no errors", func() {
+				var status bulklrpstatus.BulkLRPStatusResponse
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				err := json.Unmarshal(response.Body.Bytes(), &status)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(status.Instances).To(HaveLen(2))
+				Expect(status.Errors).To(BeEmpty())
+			})
+		})
+
+		Context("when fetching one of the actualLRPs fails", func() {
+			BeforeEach(func() {
+				bbsClient.ActualLRPGroupsByProcessGuidStub = func(logger lager.Logger, processGuid string) ([]*models.ActualLRPGroup, error) {
+					if processGuid == guid1 {
+						return []*models.ActualLRPGroup{}, nil
+					}
+					return nil, models.ErrResourceNotFound
+				}
+			})
+
+			It("reports the failure for that guid with a multi-status code", func() {
+				var status bulklrpstatus.BulkLRPStatusResponse
+
+				Expect(response.Code).To(Equal(http.StatusMultiStatus))
+				err := json.Unmarshal(response.Body.Bytes(), &status)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(status.Instances).To(HaveKey(guid1))
+				Expect(status.Instances).NotTo(HaveKey(guid2))
+				Expect(status.Errors).To(Equal(map[string]bulklrpstatus.LRPStatusError{
+					guid2: {
+						Type:    models.Error_ResourceNotFound.String(),
+						Message: models.ErrResourceNotFound.Message,
+					},
+				}))
+			})
+		})
+
+		Context("when fetching every actualLRP fails", func() {
+			BeforeEach(func() {
+				bbsClient.ActualLRPGroupsByProcessGuidReturns(nil, errors.New("boom"))
+			})
+
+			It("responds with a 500 and reports every failure", func() {
+				var status bulklrpstatus.BulkLRPStatusResponse
+
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+				err := json.Unmarshal(response.Body.Bytes(), &status)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(status.Instances).To(BeEmpty())
+				Expect(status.Errors).To(HaveLen(2))
+				Expect(status.Errors[guid1].Type).To(Equal(models.Error_UnknownError.String()))
+				Expect(status.Errors[guid1].Message).To(Equal("boom"))
+			})
+		})
+	})
 })
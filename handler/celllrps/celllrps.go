@@ -0,0 +1,62 @@
+package celllrps
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/lrpstatus"
+	"code.cloudfoundry.org/tps/handler/requestid"
+)
+
+type handler struct {
+	bbsClient bbs.Client
+	clock     clock.Clock
+	logger    lager.Logger
+}
+
+func NewHandler(bbsClient bbs.Client, clk clock.Clock, logger lager.Logger) http.Handler {
+	return &handler{
+		bbsClient: bbsClient,
+		clock:     clk,
+		logger:    logger,
+	}
+}
+
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cellID := r.FormValue(":cell_id")
+	if cellID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	logger := handler.logger.Session("cell-lrps", requestid.Data(r, lager.Data{"cell-id": cellID}))
+
+	logger.Info("fetching-actual-lrps")
+	actualLRPGroups, err := handler.bbsClient.ActualLRPGroups(logger, models.ActualLRPFilter{CellID: cellID})
+	if err != nil {
+		logger.Error("fetching-actual-lrps-failed", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	instances := lrpstatus.LRPInstances(actualLRPGroups,
+		func(instance *cc_messages.LRPInstance, actual *models.ActualLRP) {
+			instance.Host = actual.Address
+			instance.Details = actual.PlacementError
+		},
+		handler.clock,
+	)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(instances)
+	if err != nil {
+		logger.Error("stream-response-failed", err)
+	}
+}
@@ -0,0 +1,13 @@
+package celllrps_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCelllrps(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Celllrps Suite")
+}
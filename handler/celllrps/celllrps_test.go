@@ -0,0 +1,106 @@
+package celllrps_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/celllrps"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CellLRPs", func() {
+	const cellID = "cell-1"
+
+	var (
+		handler   http.Handler
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		bbsClient *fake_bbs.FakeClient
+		fakeClock *fakeclock.FakeClock
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		bbsClient = new(fake_bbs.FakeClient)
+		fakeClock = fakeclock.NewFakeClock(time.Date(2008, 8, 8, 8, 8, 8, 8, time.UTC))
+		handler = celllrps.NewHandler(bbsClient, fakeClock, lagertest.NewTestLogger("test"))
+		response = httptest.NewRecorder()
+		request, err = http.NewRequest("GET", "/v1/cells/:cell_id/actual_lrps", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		handler.ServeHTTP(response, request)
+	})
+
+	It("fails with no cell id", func() {
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	Context("with a cell id", func() {
+		var since int64
+
+		BeforeEach(func() {
+			request.Form = url.Values{}
+			request.Form.Add(":cell_id", cellID)
+
+			since = fakeClock.Now().UnixNano()
+			fakeClock.Increment(5 * time.Second)
+
+			bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{
+				{Instance: &models.ActualLRP{
+					ActualLRPKey:         models.NewActualLRPKey("guid-1", 2, "cf-apps"),
+					ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", cellID),
+					ActualLRPNetInfo:     models.NewActualLRPNetInfo("1.2.3.4", models.NewPortMapping(61000, 8080)),
+					State:                models.ActualLRPStateRunning,
+					Since:                since,
+				}},
+			}, nil)
+		})
+
+		It("fetches the actual LRPs on that cell", func() {
+			Expect(bbsClient.ActualLRPGroupsCallCount()).To(Equal(1))
+			_, filter := bbsClient.ActualLRPGroupsArgsForCall(0)
+			Expect(filter).To(Equal(models.ActualLRPFilter{CellID: cellID}))
+		})
+
+		It("returns the instances running on the cell", func() {
+			var instances []cc_messages.LRPInstance
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+			err := json.Unmarshal(response.Body.Bytes(), &instances)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(instances).To(HaveLen(1))
+			Expect(instances[0].ProcessGuid).To(Equal("guid-1"))
+			Expect(instances[0].InstanceGuid).To(Equal("instance-guid"))
+			Expect(instances[0].Index).To(BeEquivalentTo(2))
+			Expect(instances[0].State).To(Equal(cc_messages.LRPInstanceStateRunning))
+			Expect(instances[0].Host).To(Equal("1.2.3.4"))
+			Expect(instances[0].Uptime).To(BeEquivalentTo(5))
+		})
+
+		Context("when fetching actualLRPs fails", func() {
+			BeforeEach(func() {
+				bbsClient.ActualLRPGroupsReturns(nil, errors.New("boom"))
+			})
+
+			It("responds with a 500", func() {
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+})
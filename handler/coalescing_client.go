@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/metric"
+)
+
+const maxCachedLookups = 10000
+
+var errLookupPanicked = errors.New("bbs lookup panicked")
+
+const (
+	lookupCacheHits   = metric.Counter("TPSLookupCacheHits")
+	lookupCacheMisses = metric.Counter("TPSLookupCacheMisses")
+)
+
+// CoalescingBBSClient shares one BBS lookup among concurrent identical
+// requests and, with a positive TTL, reuses it for later ones. The models it
+// returns are shared with other callers and must be treated as read-only;
+// only the returned slices are the caller's own.
+type CoalescingBBSClient struct {
+	bbs.Client
+
+	clock clock.Clock
+	ttl   time.Duration
+
+	lock     sync.Mutex
+	inFlight map[string]*lookup
+	cache    map[string]cachedLookup
+
+	hits   uint64
+	misses uint64
+}
+
+type lookup struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+type cachedLookup struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+func NewCoalescingBBSClient(client bbs.Client, clk clock.Clock, ttl time.Duration) *CoalescingBBSClient {
+	return &CoalescingBBSClient{
+		Client:   client,
+		clock:    clk,
+		ttl:      ttl,
+		inFlight: make(map[string]*lookup),
+		cache:    make(map[string]cachedLookup),
+	}
+}
+
+func (c *CoalescingBBSClient) ActualLRPGroupsByProcessGuid(logger lager.Logger, processGuid string) ([]*models.ActualLRPGroup, error) {
+	value, err := c.do("actual-lrp-groups:"+processGuid, func() (interface{}, error) {
+		return c.Client.ActualLRPGroupsByProcessGuid(logger, processGuid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := value.([]*models.ActualLRPGroup)
+	return append([]*models.ActualLRPGroup(nil), groups...), nil
+}
+
+func (c *CoalescingBBSClient) DesiredLRPByProcessGuid(logger lager.Logger, processGuid string) (*models.DesiredLRP, error) {
+	value, err := c.do("desired-lrp:"+processGuid, func() (interface{}, error) {
+		return c.Client.DesiredLRPByProcessGuid(logger, processGuid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value.(*models.DesiredLRP), nil
+}
+
+func (c *CoalescingBBSClient) Hits() uint64 {
+	return atomic.LoadUint64(&c.hits)
+}
+
+func (c *CoalescingBBSClient) Misses() uint64 {
+	return atomic.LoadUint64(&c.misses)
+}
+
+func (c *CoalescingBBSClient) do(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	c.lock.Lock()
+
+	if cached, ok := c.cache[key]; ok {
+		if c.clock.Now().Before(cached.expiresAt) {
+			c.lock.Unlock()
+			c.recordHit()
+			return cached.value, nil
+		}
+		delete(c.cache, key)
+	}
+
+	if existing, ok := c.inFlight[key]; ok {
+		c.lock.Unlock()
+		existing.wg.Wait()
+		c.recordHit()
+		return existing.value, existing.err
+	}
+
+	current := &lookup{err: errLookupPanicked}
+	current.wg.Add(1)
+	c.inFlight[key] = current
+	c.lock.Unlock()
+
+	// release waiting callers and the key even if fetch panics; they see
+	// errLookupPanicked
+	defer func() {
+		c.lock.Lock()
+		delete(c.inFlight, key)
+		if current.err == nil && c.ttl > 0 {
+			c.store(key, current.value)
+		}
+		c.lock.Unlock()
+
+		current.wg.Done()
+	}()
+
+	c.recordMiss()
+	current.value, current.err = fetch()
+
+	return current.value, current.err
+}
+
+// store must be called with c.lock held.
+func (c *CoalescingBBSClient) store(key string, value interface{}) {
+	now := c.clock.Now()
+
+	if len(c.cache) >= maxCachedLookups {
+		for k, cached := range c.cache {
+			if !now.Before(cached.expiresAt) {
+				delete(c.cache, k)
+			}
+		}
+
+		if len(c.cache) >= maxCachedLookups {
+			return
+		}
+	}
+
+	c.cache[key] = cachedLookup{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *CoalescingBBSClient) recordHit() {
+	atomic.AddUint64(&c.hits, 1)
+	lookupCacheHits.Increment()
+}
+
+func (c *CoalescingBBSClient) recordMiss() {
+	atomic.AddUint64(&c.misses, 1)
+	lookupCacheMisses.Increment()
+}
@@ -0,0 +1,189 @@
+package handler_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/tps/handler"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CoalescingBBSClient", func() {
+	var (
+		bbsClient *fake_bbs.FakeClient
+		fakeClock *fakeclock.FakeClock
+		logger    *lagertest.TestLogger
+		ttl       time.Duration
+		client    *handler.CoalescingBBSClient
+	)
+
+	BeforeEach(func() {
+		bbsClient = new(fake_bbs.FakeClient)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+		ttl = 0
+	})
+
+	JustBeforeEach(func() {
+		client = handler.NewCoalescingBBSClient(bbsClient, fakeClock, ttl)
+	})
+
+	Describe("concurrent identical lookups", func() {
+		var release chan struct{}
+
+		BeforeEach(func() {
+			release = make(chan struct{})
+			bbsClient.ActualLRPGroupsByProcessGuidStub = func(lager.Logger, string) ([]*models.ActualLRPGroup, error) {
+				<-release
+				return []*models.ActualLRPGroup{{}}, nil
+			}
+		})
+
+		It("share a single BBS call", func() {
+			var wg sync.WaitGroup
+			started := make(chan struct{}, 5)
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+
+					started <- struct{}{}
+
+					groups, err := client.ActualLRPGroupsByProcessGuid(logger, "some-guid")
+					Expect(err).NotTo(HaveOccurred())
+					Expect(groups).To(HaveLen(1))
+				}()
+			}
+
+			for i := 0; i < 5; i++ {
+				Eventually(started).Should(Receive())
+			}
+			Consistently(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+			close(release)
+			wg.Wait()
+
+			Expect(bbsClient.ActualLRPGroupsByProcessGuidCallCount()).To(Equal(1))
+			Expect(client.Misses()).To(BeEquivalentTo(1))
+			Expect(client.Hits()).To(BeEquivalentTo(4))
+		})
+	})
+
+	Context("when a lookup panics", func() {
+		BeforeEach(func() {
+			bbsClient.ActualLRPGroupsByProcessGuidStub = func(lager.Logger, string) ([]*models.ActualLRPGroup, error) {
+				panic("boom")
+			}
+		})
+
+		It("does not wedge later lookups of the same key", func() {
+			Expect(func() { client.ActualLRPGroupsByProcessGuid(logger, "some-guid") }).To(Panic())
+
+			bbsClient.ActualLRPGroupsByProcessGuidStub = nil
+			bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{{}}, nil)
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				defer GinkgoRecover()
+
+				groups, err := client.ActualLRPGroupsByProcessGuid(logger, "some-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(groups).To(HaveLen(1))
+			}()
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("with a TTL", func() {
+		BeforeEach(func() {
+			ttl = time.Second
+			bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{{}, {}}, nil)
+		})
+
+		It("gives every caller its own slice of groups", func() {
+			groups, err := client.ActualLRPGroupsByProcessGuid(logger, "some-guid")
+			Expect(err).NotTo(HaveOccurred())
+			groups[0] = nil
+
+			groups, err = client.ActualLRPGroupsByProcessGuid(logger, "some-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(groups[0]).NotTo(BeNil())
+			Expect(bbsClient.ActualLRPGroupsByProcessGuidCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("sequential lookups", func() {
+		BeforeEach(func() {
+			bbsClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{ProcessGuid: "some-guid"}, nil)
+		})
+
+		Context("without a TTL", func() {
+			It("calls the BBS every time", func() {
+				_, err := client.DesiredLRPByProcessGuid(logger, "some-guid")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = client.DesiredLRPByProcessGuid(logger, "some-guid")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(2))
+				Expect(client.Misses()).To(BeEquivalentTo(2))
+			})
+		})
+
+		Context("with a TTL", func() {
+			BeforeEach(func() {
+				ttl = time.Second
+			})
+
+			It("reuses the result until it expires", func() {
+				desiredLRP, err := client.DesiredLRPByProcessGuid(logger, "some-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(desiredLRP.ProcessGuid).To(Equal("some-guid"))
+
+				_, err = client.DesiredLRPByProcessGuid(logger, "some-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(1))
+				Expect(client.Hits()).To(BeEquivalentTo(1))
+
+				fakeClock.Increment(time.Second)
+
+				_, err = client.DesiredLRPByProcessGuid(logger, "some-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(2))
+			})
+
+			It("does not share results between different guids", func() {
+				_, err := client.DesiredLRPByProcessGuid(logger, "some-guid")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = client.DesiredLRPByProcessGuid(logger, "other-guid")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(2))
+			})
+
+			Context("when the lookup fails", func() {
+				BeforeEach(func() {
+					bbsClient.DesiredLRPByProcessGuidReturns(nil, errors.New("boom"))
+				})
+
+				It("does not cache the failure", func() {
+					_, err := client.DesiredLRPByProcessGuid(logger, "some-guid")
+					Expect(err).To(MatchError("boom"))
+					_, err = client.DesiredLRPByProcessGuid(logger, "some-guid")
+					Expect(err).To(MatchError("boom"))
+
+					Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(2))
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/tps"
+)
+
+// routeBudgets names the budget shared by routes that serve the same
+// request. Other routes have a budget of their own name.
+var routeBudgets = map[string]string{
+	tps.PostBulkLRPStatus:   tps.BulkLRPStatus,
+	tps.BulkLRPStatusV2:     tps.BulkLRPStatus,
+	tps.PostBulkLRPStatusV2: tps.BulkLRPStatus,
+}
+
+// RouteBudget returns the name of the per-route budget that route draws on.
+func RouteBudget(route string) string {
+	if budget, ok := routeBudgets[route]; ok {
+		return budget
+	}
+	return route
+}
+
+type ConcurrencyLimits struct {
+	// MaxInFlight bounds the requests served at once across all routes but
+	// the streaming ones.
+	MaxInFlight int
+	// MaxStreams bounds the event streams held open at once. Streams last as
+	// long as their client listens, so they draw on this budget instead of
+	// MaxInFlight and cannot starve the other routes. Zero disables the limit.
+	MaxStreams int
+	// RouteMaxInFlight bounds the requests served at once per route budget,
+	// as named by RouteBudget. The variants of a route share its budget.
+	RouteMaxInFlight map[string]int
+	// ClientMaxInFlight bounds the requests served at once per client, as
+	// identified by its certificate common name or remote address. Zero
+	// disables the per-client budget.
+	ClientMaxInFlight int
+	// MaxQueued is how many requests may wait for each budget before further
+	// requests are rejected outright. Zero rejects every request over a
+	// budget at once.
+	MaxQueued int
+	// MaxQueueWait is how long a queued request waits before it is rejected.
+	MaxQueueWait time.Duration
+}
+
+type budget struct {
+	slots chan struct{}
+	queue chan struct{}
+}
+
+func newBudget(size, maxQueued int) *budget {
+	return &budget{
+		slots: make(chan struct{}, size),
+		queue: make(chan struct{}, maxQueued),
+	}
+}
+
+func (b *budget) acquire(done <-chan struct{}) bool {
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	select {
+	case b.queue <- struct{}{}:
+	default:
+		return false
+	}
+
+	defer func() {
+		<-b.queue
+	}()
+
+	select {
+	case b.slots <- struct{}{}:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func (b *budget) release() {
+	<-b.slots
+}
+
+type clientBudget struct {
+	*budget
+	users int
+}
+
+type clientBudgets struct {
+	lock      sync.Mutex
+	size      int
+	maxQueued int
+	budgets   map[string]*clientBudget
+}
+
+func (c *clientBudgets) get(client string) *budget {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	b, ok := c.budgets[client]
+	if !ok {
+		b = &clientBudget{budget: newBudget(c.size, c.maxQueued)}
+		c.budgets[client] = b
+	}
+	b.users++
+
+	return b.budget
+}
+
+func (c *clientBudgets) put(client string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	b := c.budgets[client]
+	b.users--
+	if b.users == 0 {
+		delete(c.budgets, client)
+	}
+}
+
+type concurrencyLimiter struct {
+	global       *budget
+	streams      *budget
+	routes       map[string]*budget
+	clients      *clientBudgets
+	maxQueueWait time.Duration
+}
+
+func newConcurrencyLimiter(limits ConcurrencyLimits) *concurrencyLimiter {
+	limiter := &concurrencyLimiter{
+		global:       newBudget(limits.MaxInFlight, limits.MaxQueued),
+		routes:       make(map[string]*budget, len(limits.RouteMaxInFlight)),
+		maxQueueWait: limits.MaxQueueWait,
+	}
+
+	if limits.MaxStreams > 0 {
+		limiter.streams = newBudget(limits.MaxStreams, limits.MaxQueued)
+	}
+
+	for route, size := range limits.RouteMaxInFlight {
+		limiter.routes[RouteBudget(route)] = newBudget(size, limits.MaxQueued)
+	}
+
+	if limits.ClientMaxInFlight > 0 {
+		limiter.clients = &clientBudgets{
+			size:      limits.ClientMaxInFlight,
+			maxQueued: limits.MaxQueued,
+			budgets:   make(map[string]*clientBudget),
+		}
+	}
+
+	return limiter
+}
+
+// acquire takes a slot from the client, route and global budgets in that
+// order, waiting at most maxQueueWait in total. Streaming routes take a slot
+// from the streams budget in place of the global one. The returned function
+// gives the slots back.
+func (limiter *concurrencyLimiter) acquire(r *http.Request, route string) (func(), bool) {
+	ctx, cancel := context.WithTimeout(r.Context(), limiter.maxQueueWait)
+	defer cancel()
+
+	releases := []func(){}
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+
+	if limiter.clients != nil {
+		client := clientIdentity(r)
+		clientBudget := limiter.clients.get(client)
+		if !clientBudget.acquire(ctx.Done()) {
+			limiter.clients.put(client)
+			return nil, false
+		}
+		releases = append(releases, func() {
+			clientBudget.release()
+			limiter.clients.put(client)
+		})
+	}
+
+	if routeBudget, ok := limiter.routes[RouteBudget(route)]; ok {
+		if !routeBudget.acquire(ctx.Done()) {
+			release()
+			return nil, false
+		}
+		releases = append(releases, routeBudget.release)
+	}
+
+	global := limiter.global
+	if streamingRoutes[route] {
+		global = limiter.streams
+	}
+
+	if global != nil {
+		if !global.acquire(ctx.Done()) {
+			release()
+			return nil, false
+		}
+		releases = append(releases, global.release)
+	}
+
+	return release, true
+}
+
+func (limiter *concurrencyLimiter) retryAfter() string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(limiter.maxQueueWait.Seconds()))))
+}
+
+func clientIdentity(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return "subject:" + r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "address:" + r.RemoteAddr
+	}
+
+	return "address:" + host
+}
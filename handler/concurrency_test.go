@@ -0,0 +1,236 @@
+package handler_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/events/eventfakes"
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/tps"
+	"code.cloudfoundry.org/tps/handler"
+	"code.cloudfoundry.org/tps/handler/lrpstats/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concurrency limits", func() {
+	var (
+		bbsClient   *fake_bbs.FakeClient
+		limits      handler.ConcurrencyLimits
+		httpHandler http.Handler
+		unblock     chan struct{}
+	)
+
+	BeforeEach(func() {
+		bbsClient = new(fake_bbs.FakeClient)
+		unblock = make(chan struct{})
+
+		bbsClient.ActualLRPGroupsByProcessGuidStub = func(logger lager.Logger, guid string) ([]*models.ActualLRPGroup, error) {
+			if guid == "slow-guid" {
+				<-unblock
+			}
+			return []*models.ActualLRPGroup{}, nil
+		}
+
+		limits = handler.ConcurrencyLimits{MaxInFlight: 10}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		httpHandler, err = handler.New(bbsClient, &fakes.FakeMetricsProvider{}, nil, nil, nil, limits, 15, 0, lagertest.NewTestLogger("test"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		close(unblock)
+	})
+
+	request := func(path, client string) *http.Request {
+		req, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.RemoteAddr = client + ":12345"
+		return req
+	}
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		httpHandler.ServeHTTP(response, req)
+		return response
+	}
+
+	serveInBackground := func(req *http.Request) <-chan *httptest.ResponseRecorder {
+		responses := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			defer GinkgoRecover()
+			responses <- serve(req)
+		}()
+		return responses
+	}
+
+	Context("with a per-route budget", func() {
+		BeforeEach(func() {
+			limits.RouteMaxInFlight = map[string]int{tps.BulkLRPStatus: 1}
+		})
+
+		It("rejects requests over the route budget without affecting other routes", func() {
+			slow := serveInBackground(request("/v1/bulk_actual_lrp_status?guids=slow-guid", "10.0.0.1"))
+			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+			rejected := serve(request("/v1/bulk_actual_lrp_status?guids=other-guid", "10.0.0.2"))
+			Expect(rejected.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(rejected.Header().Get("Retry-After")).To(Equal("1"))
+
+			Expect(serve(request("/v1/actual_lrps/other-guid", "10.0.0.2")).Code).To(Equal(http.StatusOK))
+
+			unblock <- struct{}{}
+			Eventually(slow).Should(Receive())
+		})
+
+		It("shares the budget with the POST and v2 variants of the route", func() {
+			slow := serveInBackground(request("/v1/bulk_actual_lrp_status?guids=slow-guid", "10.0.0.1"))
+			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+			Expect(serve(request("/v2/bulk_actual_lrp_status?guids=other-guid", "10.0.0.2")).Code).To(Equal(http.StatusServiceUnavailable))
+
+			post, err := http.NewRequest("POST", "/v1/bulk_actual_lrp_status", strings.NewReader(`{"process_guids": ["other-guid"]}`))
+			Expect(err).NotTo(HaveOccurred())
+			post.RemoteAddr = "10.0.0.2:12345"
+			Expect(serve(post).Code).To(Equal(http.StatusServiceUnavailable))
+
+			unblock <- struct{}{}
+			Eventually(slow).Should(Receive())
+		})
+	})
+
+	Context("with event streams open", func() {
+		var eventSource *eventfakes.FakeEventSource
+
+		BeforeEach(func() {
+			limits.MaxInFlight = 1
+			limits.MaxStreams = 1
+
+			eventSource = new(eventfakes.FakeEventSource)
+			eventSource.NextStub = func() (models.Event, error) {
+				<-unblock
+				return nil, events.ErrSourceClosed
+			}
+			bbsClient.SubscribeToEventsReturns(eventSource, nil)
+		})
+
+		It("serves other routes regardless, and bounds the streams by their own budget", func() {
+			stream := serveInBackground(request("/v1/actual_lrps/some-guid/events", "10.0.0.1"))
+			Eventually(eventSource.NextCallCount).Should(Equal(1))
+
+			Expect(serve(request("/v1/actual_lrps/other-guid", "10.0.0.2")).Code).To(Equal(http.StatusOK))
+			Expect(serve(request("/v1/actual_lrps/other-guid/events", "10.0.0.2")).Code).To(Equal(http.StatusServiceUnavailable))
+
+			unblock <- struct{}{}
+			Eventually(stream).Should(Receive())
+		})
+	})
+
+	Context("with a per-client budget", func() {
+		BeforeEach(func() {
+			limits.ClientMaxInFlight = 1
+		})
+
+		It("rejects a client over its budget without affecting other clients", func() {
+			slow := serveInBackground(request("/v1/actual_lrps/slow-guid", "10.0.0.1"))
+			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+			Expect(serve(request("/v1/actual_lrps/other-guid", "10.0.0.1")).Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(serve(request("/v1/actual_lrps/other-guid", "10.0.0.2")).Code).To(Equal(http.StatusOK))
+
+			unblock <- struct{}{}
+			Eventually(slow).Should(Receive())
+
+			Expect(serve(request("/v1/actual_lrps/other-guid", "10.0.0.1")).Code).To(Equal(http.StatusOK))
+		})
+
+		It("identifies clients by their certificate common name", func() {
+			withSubject := func(req *http.Request, commonName string) *http.Request {
+				req.TLS = &tls.ConnectionState{
+					PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}},
+				}
+				return req
+			}
+
+			slow := serveInBackground(withSubject(request("/v1/actual_lrps/slow-guid", "10.0.0.1"), "dashboard"))
+			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+			Expect(serve(withSubject(request("/v1/actual_lrps/other-guid", "10.0.0.2"), "dashboard")).Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(serve(withSubject(request("/v1/actual_lrps/other-guid", "10.0.0.1"), "cloud-controller")).Code).To(Equal(http.StatusOK))
+
+			unblock <- struct{}{}
+			Eventually(slow).Should(Receive())
+		})
+	})
+
+	Context("with a wait queue", func() {
+		BeforeEach(func() {
+			limits.MaxInFlight = 1
+			limits.MaxQueued = 1
+			limits.MaxQueueWait = 5 * time.Second
+		})
+
+		It("serves a queued request once a slot frees up", func() {
+			slow := serveInBackground(request("/v1/actual_lrps/slow-guid", "10.0.0.1"))
+			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+			queued := serveInBackground(request("/v1/actual_lrps/other-guid", "10.0.0.2"))
+			Consistently(queued).ShouldNot(Receive())
+
+			unblock <- struct{}{}
+			Eventually(slow).Should(Receive())
+
+			var response *httptest.ResponseRecorder
+			Eventually(queued).Should(Receive(&response))
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("rejects requests once the queue is full", func() {
+			slow := serveInBackground(request("/v1/actual_lrps/slow-guid", "10.0.0.1"))
+			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+			queued := serveInBackground(request("/v1/actual_lrps/other-guid", "10.0.0.2"))
+			Consistently(queued).ShouldNot(Receive())
+
+			rejected := serve(request("/v1/actual_lrps/other-guid", "10.0.0.3"))
+			Expect(rejected.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(rejected.Header().Get("Retry-After")).To(Equal("5"))
+
+			unblock <- struct{}{}
+			Eventually(slow).Should(Receive())
+			Eventually(queued).Should(Receive())
+		})
+
+		Context("when the deadline passes", func() {
+			BeforeEach(func() {
+				limits.MaxQueueWait = 100 * time.Millisecond
+			})
+
+			It("rejects the queued request", func() {
+				slow := serveInBackground(request("/v1/actual_lrps/slow-guid", "10.0.0.1"))
+				Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
+				var response *httptest.ResponseRecorder
+				Eventually(serveInBackground(request("/v1/actual_lrps/other-guid", "10.0.0.2"))).Should(Receive(&response))
+				Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(response.Header().Get("Retry-After")).To(Equal("1"))
+
+				unblock <- struct{}{}
+				Eventually(slow).Should(Receive())
+			})
+		})
+	})
+})
@@ -0,0 +1,66 @@
+package etag
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+)
+
+// Compute returns a weak ETag for the JSON representation of v. Callers should
+// clear time-dependent fields such as Uptime first; see WithoutUptime.
+func Compute(v interface{}) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum(payload)
+	return `W/"` + hex.EncodeToString(sum[:]) + `"`, nil
+}
+
+func WithoutUptime(instances []cc_messages.LRPInstance) []cc_messages.LRPInstance {
+	stripped := make([]cc_messages.LRPInstance, len(instances))
+	for i, instance := range instances {
+		instance.Uptime = 0
+		stripped[i] = instance
+	}
+
+	return stripped
+}
+
+func Matches(r *http.Request, tag string) bool {
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckNotModified sets the ETag header computed from fingerprint and, when it
+// matches the request's If-None-Match, responds with 304 Not Modified.
+func CheckNotModified(w http.ResponseWriter, r *http.Request, fingerprint interface{}) (bool, error) {
+	tag, err := Compute(fingerprint)
+	if err != nil {
+		return false, err
+	}
+
+	w.Header().Set("ETag", tag)
+	if Matches(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true, nil
+	}
+
+	return false, nil
+}
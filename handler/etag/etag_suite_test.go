@@ -0,0 +1,13 @@
+package etag_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEtag(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ETag Suite")
+}
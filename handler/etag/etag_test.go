@@ -0,0 +1,92 @@
+package etag_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/etag"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ETag", func() {
+	var instances []cc_messages.LRPInstance
+
+	BeforeEach(func() {
+		instances = []cc_messages.LRPInstance{{
+			ProcessGuid:  "guid",
+			InstanceGuid: "instance-guid",
+			State:        cc_messages.LRPInstanceStateRunning,
+			Since:        100,
+			Uptime:       5,
+		}}
+	})
+
+	Describe("Compute", func() {
+		It("ignores uptime", func() {
+			before, err := etag.Compute(etag.WithoutUptime(instances))
+			Expect(err).NotTo(HaveOccurred())
+
+			instances[0].Uptime = 10
+			after, err := etag.Compute(etag.WithoutUptime(instances))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(after).To(Equal(before))
+		})
+
+		It("changes with the state, instance guid and since", func() {
+			original, err := etag.Compute(etag.WithoutUptime(instances))
+			Expect(err).NotTo(HaveOccurred())
+
+			instances[0].State = cc_messages.LRPInstanceStateCrashed
+			crashed, err := etag.Compute(etag.WithoutUptime(instances))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(crashed).NotTo(Equal(original))
+
+			instances[0].InstanceGuid = "other-instance-guid"
+			replaced, err := etag.Compute(etag.WithoutUptime(instances))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replaced).NotTo(Equal(crashed))
+
+			instances[0].Since = 200
+			restarted, err := etag.Compute(etag.WithoutUptime(instances))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(restarted).NotTo(Equal(replaced))
+		})
+
+		It("does not modify the given instances", func() {
+			etag.WithoutUptime(instances)
+			Expect(instances[0].Uptime).To(BeEquivalentTo(5))
+		})
+	})
+
+	Describe("Matches", func() {
+		var request *http.Request
+
+		BeforeEach(func() {
+			var err error
+			request, err = http.NewRequest("GET", "/", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not match without If-None-Match", func() {
+			Expect(etag.Matches(request, `W/"abc"`)).To(BeFalse())
+		})
+
+		It("matches any of the listed tags, weak or strong", func() {
+			request.Header.Set("If-None-Match", `"xyz", "abc"`)
+			Expect(etag.Matches(request, `W/"abc"`)).To(BeTrue())
+		})
+
+		It("matches a wildcard", func() {
+			request.Header.Set("If-None-Match", "*")
+			Expect(etag.Matches(request, `W/"abc"`)).To(BeTrue())
+		})
+
+		It("does not match other tags", func() {
+			request.Header.Set("If-None-Match", `W/"xyz"`)
+			Expect(etag.Matches(request, `W/"abc"`)).To(BeFalse())
+		})
+	})
+})
@@ -2,54 +2,94 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/tps"
+	"code.cloudfoundry.org/tps/handler/bulklrpstats"
 	"code.cloudfoundry.org/tps/handler/bulklrpstatus"
+	"code.cloudfoundry.org/tps/handler/celllrps"
+	"code.cloudfoundry.org/tps/handler/lrpevents"
+	"code.cloudfoundry.org/tps/handler/lrplist"
 	"code.cloudfoundry.org/tps/handler/lrpstats"
 	"code.cloudfoundry.org/tps/handler/lrpstatus"
+	"code.cloudfoundry.org/tps/handler/lrpsummary"
+	"code.cloudfoundry.org/tps/handler/metricshistory"
+	"code.cloudfoundry.org/tps/handler/prommetrics"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
 	"github.com/tedsuo/rata"
 )
 
-func New(apiClient bbs.Client, noaaClient lrpstats.NoaaClient, maxInFlight, bulkLRPStatusWorkers int, logger lager.Logger) (http.Handler, error) {
-	semaphore := make(chan struct{}, maxInFlight)
+func New(apiClient bbs.Client, metricsProvider lrpstats.MetricsProvider, metricsHistory *metricshistory.History, tokenValidator tokenauth.Validator, subjectRoutes SubjectRoutes, limits ConcurrencyLimits, bulkLRPStatusWorkers int, lookupCacheTTL time.Duration, logger lager.Logger) (http.Handler, error) {
+	limiter := newConcurrencyLimiter(limits)
 	clock := clock.NewClock()
+	apiClient = NewCoalescingBBSClient(NewInstrumentedBBSClient(apiClient), clock, lookupCacheTTL)
+	metricsProvider = NewInstrumentedMetricsProvider(metricsProvider)
+
+	bulkLRPStatusHandler := bulklrpstatus.NewHandler(apiClient, clock, bulkLRPStatusWorkers, logger)
+	bulkLRPStatusV2Handler := bulklrpstatus.NewV2Handler(apiClient, clock, bulkLRPStatusWorkers, logger)
 
 	handlers := map[string]http.Handler{
-		tps.LRPStatus: tpsHandler{
-			semaphore:       semaphore,
-			delegateHandler: LogWrap(lrpstatus.NewHandler(apiClient, clock, logger), logger),
-		},
-		tps.LRPStats: tpsHandler{
-			semaphore:       semaphore,
-			delegateHandler: LogWrap(lrpstats.NewHandler(apiClient, noaaClient, clock, logger), logger),
-		},
-		tps.BulkLRPStatus: tpsHandler{
-			semaphore:       semaphore,
-			delegateHandler: LogWrap(bulklrpstatus.NewHandler(apiClient, clock, bulkLRPStatusWorkers, logger), logger),
-		},
+		tps.LRPStatus:           lrpstatus.NewHandler(apiClient, clock, logger),
+		tps.LRPStats:            lrpstats.NewHandler(apiClient, metricsProvider, metricsHistory, tokenValidator, clock, logger),
+		tps.LRPEvents:           lrpevents.NewHandler(apiClient, clock, logger),
+		tps.ListActualLRPs:      lrplist.NewHandler(apiClient, clock, logger),
+		tps.LRPSummary:          lrpsummary.NewHandler(apiClient, clock, logger),
+		tps.CellLRPs:            celllrps.NewHandler(apiClient, clock, logger),
+		tps.BulkLRPStatus:       bulkLRPStatusHandler,
+		tps.PostBulkLRPStatus:   bulkLRPStatusHandler,
+		tps.BulkLRPStatusV2:     bulkLRPStatusV2Handler,
+		tps.PostBulkLRPStatusV2: bulkLRPStatusV2Handler,
+		tps.BulkLRPStats:        bulklrpstats.NewHandler(apiClient, metricsProvider, tokenValidator, clock, bulkLRPStatusWorkers, logger),
+	}
+
+	for route, delegateHandler := range handlers {
+		handlers[route] = tpsHandler{
+			route:           route,
+			limiter:         limiter,
+			delegateHandler: delegateHandler,
+		}
+	}
+
+	if subjectRoutes != nil {
+		for route, delegateHandler := range handlers {
+			handlers[route] = subjectRouteHandler{
+				route:           route,
+				subjectRoutes:   subjectRoutes,
+				delegateHandler: delegateHandler,
+			}
+		}
+	}
+
+	for route, delegateHandler := range handlers {
+		handlers[route] = LogWrap(instrumentedHandler{route: route, delegateHandler: delegateHandler}, logger)
 	}
 
 	return rata.NewRouter(tps.Routes, handlers)
 }
 
 type tpsHandler struct {
-	semaphore       chan struct{}
+	route           string
+	limiter         *concurrencyLimiter
 	delegateHandler http.Handler
 }
 
 func (handler tpsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	select {
-	case handler.semaphore <- struct{}{}:
-	default:
+	release, ok := handler.limiter.acquire(r, handler.route)
+	if !ok {
+		prommetrics.RejectedRequests.WithLabelValues(handler.route).Inc()
+		w.Header().Set("Retry-After", handler.limiter.retryAfter())
 		w.WriteHeader(http.StatusServiceUnavailable)
 		return
 	}
 
+	inFlight := prommetrics.InFlightRequests.WithLabelValues(handler.route)
+	inFlight.Inc()
 	defer func() {
-		<-handler.semaphore
+		inFlight.Dec()
+		release()
 	}()
 
 	handler.delegateHandler.ServeHTTP(w, r)
@@ -23,8 +23,8 @@ var _ = Describe("Handler", func() {
 	Describe("rate limiting", func() {
 
 		var (
-			noaaClient *fakes.FakeNoaaClient
-			bbsClient  *fake_bbs.FakeClient
+			metricsProvider *fakes.FakeMetricsProvider
+			bbsClient       *fake_bbs.FakeClient
 
 			logger *lagertest.TestLogger
 
@@ -42,14 +42,14 @@ var _ = Describe("Handler", func() {
 			httpClient = &http.Client{}
 			logger = lagertest.NewTestLogger("test")
 			bbsClient = new(fake_bbs.FakeClient)
-			noaaClient = &fakes.FakeNoaaClient{}
+			metricsProvider = &fakes.FakeMetricsProvider{}
 
-			httpHandler, err = handler.New(bbsClient, noaaClient, 2, 15, logger)
+			httpHandler, err = handler.New(bbsClient, metricsProvider, nil, nil, nil, handler.ConcurrencyLimits{MaxInFlight: 2}, 15, 0, logger)
 			Expect(err).NotTo(HaveOccurred())
 
 			server = httptest.NewServer(httpHandler)
 
-			fakeActualLRPResponses = make(chan []*models.ActualLRPGroup, 2)
+			fakeActualLRPResponses = make(chan []*models.ActualLRPGroup, 3)
 
 			bbsClient.DesiredLRPByProcessGuidStub = func(lager.Logger, string) (*models.DesiredLRP, error) {
 				return &models.DesiredLRP{}, nil
@@ -59,7 +59,7 @@ var _ = Describe("Handler", func() {
 				return <-fakeActualLRPResponses, nil
 			}
 
-			noaaClient.ContainerMetricsReturns([]*events.ContainerMetric{
+			metricsProvider.ContainerMetricsReturns([]*events.ContainerMetric{
 				{
 					ApplicationId: proto.String("appId"),
 					InstanceIndex: proto.Int32(0),
@@ -97,6 +97,8 @@ var _ = Describe("Handler", func() {
 				Expect(res.StatusCode).To(Equal(http.StatusOK))
 			}()
 
+			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(1))
+
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
@@ -107,12 +109,15 @@ var _ = Describe("Handler", func() {
 				Expect(res.StatusCode).To(Equal(http.StatusOK))
 			}()
 
-			Eventually(bbsClient.ActualLRPGroupsByProcessGuidCallCount).Should(Equal(2))
+			// the stats request looks up the desired LRP, then waits on the
+			// status request's lookup of the same actual LRPs
+			Eventually(bbsClient.DesiredLRPByProcessGuidCallCount).Should(Equal(1))
 
 			// hit it again, assert we get a 503
 			res, err := httpClient.Do(statusRequest)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res.StatusCode).To(Equal(http.StatusServiceUnavailable))
+			Expect(res.Header.Get("Retry-After")).To(Equal("1"))
 
 			res, err = httpClient.Do(statsRequest)
 			Expect(err).NotTo(HaveOccurred())
@@ -120,7 +125,6 @@ var _ = Describe("Handler", func() {
 
 			// un-hang http calls
 			fakeActualLRPResponses <- []*models.ActualLRPGroup{}
-			fakeActualLRPResponses <- []*models.ActualLRPGroup{}
 			wg.Wait()
 
 			// confirm we can request again
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	bbsevents "code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps"
+	"code.cloudfoundry.org/tps/handler/lrpstats"
+	"code.cloudfoundry.org/tps/handler/prommetrics"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+type InstrumentedBBSClient struct {
+	bbs.Client
+}
+
+func NewInstrumentedBBSClient(client bbs.Client) *InstrumentedBBSClient {
+	return &InstrumentedBBSClient{Client: client}
+}
+
+func (c *InstrumentedBBSClient) ActualLRPGroups(logger lager.Logger, filter models.ActualLRPFilter) ([]*models.ActualLRPGroup, error) {
+	defer observeBBSRequest("ActualLRPGroups", time.Now())
+	groups, err := c.Client.ActualLRPGroups(logger, filter)
+	countBBSError("ActualLRPGroups", err)
+	return groups, err
+}
+
+func (c *InstrumentedBBSClient) ActualLRPGroupsByProcessGuid(logger lager.Logger, processGuid string) ([]*models.ActualLRPGroup, error) {
+	defer observeBBSRequest("ActualLRPGroupsByProcessGuid", time.Now())
+	groups, err := c.Client.ActualLRPGroupsByProcessGuid(logger, processGuid)
+	countBBSError("ActualLRPGroupsByProcessGuid", err)
+	return groups, err
+}
+
+func (c *InstrumentedBBSClient) DesiredLRPByProcessGuid(logger lager.Logger, processGuid string) (*models.DesiredLRP, error) {
+	defer observeBBSRequest("DesiredLRPByProcessGuid", time.Now())
+	desiredLRP, err := c.Client.DesiredLRPByProcessGuid(logger, processGuid)
+	countBBSError("DesiredLRPByProcessGuid", err)
+	return desiredLRP, err
+}
+
+func (c *InstrumentedBBSClient) SubscribeToEvents(logger lager.Logger) (bbsevents.EventSource, error) {
+	defer observeBBSRequest("SubscribeToEvents", time.Now())
+	eventSource, err := c.Client.SubscribeToEvents(logger)
+	countBBSError("SubscribeToEvents", err)
+	return eventSource, err
+}
+
+func observeBBSRequest(method string, start time.Time) {
+	prommetrics.BBSRequestDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// countBBSError counts failed BBS calls. A missing resource is an answer,
+// not a failure, and is not counted.
+func countBBSError(method string, err error) {
+	if err == nil || models.ConvertError(err).Type == models.Error_ResourceNotFound {
+		return
+	}
+
+	prommetrics.BBSRequestErrors.WithLabelValues(method).Inc()
+}
+
+type instrumentedMetricsProvider struct {
+	lrpstats.MetricsProvider
+}
+
+func NewInstrumentedMetricsProvider(provider lrpstats.MetricsProvider) lrpstats.MetricsProvider {
+	return &instrumentedMetricsProvider{MetricsProvider: provider}
+}
+
+func (p *instrumentedMetricsProvider) ContainerMetrics(appGuid string, authToken string) ([]*events.ContainerMetric, error) {
+	start := time.Now()
+	metrics, err := p.MetricsProvider.ContainerMetrics(appGuid, authToken)
+	prommetrics.MetricsProviderRequestDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		prommetrics.MetricsProviderRequestErrors.Inc()
+	}
+
+	return metrics, err
+}
+
+// streamingRoutes hold their response open for as long as the client
+// listens, so their durations are kept apart from request latencies and they
+// have a concurrency budget of their own.
+var streamingRoutes = map[string]bool{
+	tps.LRPEvents: true,
+}
+
+type instrumentedHandler struct {
+	route           string
+	delegateHandler http.Handler
+}
+
+func (handler instrumentedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	recorder := &responseRecorder{ResponseWriter: w}
+	handler.delegateHandler.ServeHTTP(recorder, r)
+
+	duration := prommetrics.RequestDuration
+	if streamingRoutes[handler.route] {
+		duration = prommetrics.StreamDuration
+	}
+	duration.WithLabelValues(handler.route).Observe(time.Since(start).Seconds())
+	prommetrics.Requests.WithLabelValues(handler.route, strconv.Itoa(recorder.Status())).Inc()
+}
@@ -0,0 +1,129 @@
+package handler_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/tps/handler"
+	"code.cloudfoundry.org/tps/handler/lrpstats/fakes"
+	"code.cloudfoundry.org/tps/handler/prommetrics"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instrumentation", func() {
+	var (
+		bbsClient       *fake_bbs.FakeClient
+		metricsProvider *fakes.FakeMetricsProvider
+		httpHandler     http.Handler
+	)
+
+	scrape := func() string {
+		response := httptest.NewRecorder()
+		request, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		prommetrics.Handler().ServeHTTP(response, request)
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		body, err := ioutil.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return string(body)
+	}
+
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		request, err := http.NewRequest("GET", path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		for key := range header {
+			request.Header.Set(key, header.Get(key))
+		}
+
+		response := httptest.NewRecorder()
+		httpHandler.ServeHTTP(response, request)
+		return response
+	}
+
+	BeforeEach(func() {
+		bbsClient = new(fake_bbs.FakeClient)
+		metricsProvider = &fakes.FakeMetricsProvider{}
+
+		var err error
+		httpHandler, err = handler.New(bbsClient, metricsProvider, nil, nil, nil, handler.ConcurrencyLimits{MaxInFlight: 10}, 15, 0, lagertest.NewTestLogger("test"))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("counts requests and their latency per route", func() {
+		bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{}, nil)
+		Expect(serve("/v1/actual_lrps/instrumented-guid", nil).Code).To(Equal(http.StatusOK))
+
+		metrics := scrape()
+		Expect(metrics).To(MatchRegexp(`tps_listener_requests_total\{code="200",route="LRPStatus"\} \d+`))
+		Expect(metrics).To(MatchRegexp(`tps_listener_request_duration_seconds_count\{route="LRPStatus"\} \d+`))
+		Expect(metrics).To(MatchRegexp(`tps_listener_in_flight_requests\{route="LRPStatus"\} 0`))
+		Expect(metrics).To(MatchRegexp(`tps_listener_bbs_request_duration_seconds_count\{method="ActualLRPGroupsByProcessGuid"\} \d+`))
+	})
+
+	It("counts failed BBS and metrics provider calls", func() {
+		bbsClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{LogGuid: "log-guid"}, nil)
+		bbsClient.ActualLRPGroupsByProcessGuidReturns(nil, errors.New("boom"))
+		metricsProvider.ContainerMetricsReturns(nil, errors.New("boom"))
+
+		Expect(serve("/v1/actual_lrps/failing-guid/stats", http.Header{"Authorization": {"bearer token"}}).Code).To(Equal(http.StatusInternalServerError))
+
+		bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{}, nil)
+		Expect(serve("/v1/actual_lrps/other-failing-guid/stats", http.Header{"Authorization": {"bearer token"}}).Code).To(Equal(http.StatusOK))
+
+		metrics := scrape()
+		Expect(metrics).To(MatchRegexp(`tps_listener_bbs_request_errors_total\{method="ActualLRPGroupsByProcessGuid"\} [1-9]\d*`))
+		Expect(metrics).To(MatchRegexp(`tps_listener_metrics_provider_request_errors_total [1-9]\d*`))
+		Expect(metrics).To(MatchRegexp(`tps_listener_requests_total\{code="500",route="LRPStats"\} [1-9]\d*`))
+	})
+
+	It("does not count missing resources as failed BBS calls", func() {
+		bbsClient.DesiredLRPByProcessGuidReturns(nil, models.ErrResourceNotFound)
+
+		Expect(serve("/v1/actual_lrps/missing-guid/stats", http.Header{"Authorization": {"bearer token"}}).Code).To(Equal(http.StatusNotFound))
+
+		metrics := scrape()
+		Expect(metrics).To(MatchRegexp(`tps_listener_bbs_request_duration_seconds_count\{method="DesiredLRPByProcessGuid"\} \d+`))
+		Expect(metrics).NotTo(ContainSubstring(`tps_listener_bbs_request_errors_total{method="DesiredLRPByProcessGuid"}`))
+	})
+
+	It("keeps event streams out of the request latencies", func() {
+		bbsClient.SubscribeToEventsReturns(nil, errors.New("boom"))
+		serve("/v1/actual_lrps/streamed-guid/events", nil)
+
+		metrics := scrape()
+		Expect(metrics).To(MatchRegexp(`tps_listener_stream_duration_seconds_count\{route="LRPEvents"\} [1-9]\d*`))
+		Expect(metrics).NotTo(ContainSubstring(`tps_listener_request_duration_seconds_count{route="LRPEvents"}`))
+	})
+
+	It("reports the bulk workpool queue depth", func() {
+		bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{}, nil)
+		Expect(serve("/v1/bulk_actual_lrp_status?guids=a,b,c", nil).Code).To(Equal(http.StatusOK))
+
+		Expect(scrape()).To(MatchRegexp(`tps_listener_bulk_work_queue_depth\{handler="bulk-lrp-status"\} 0`))
+	})
+
+	Context("when a request is rejected", func() {
+		BeforeEach(func() {
+			var err error
+			httpHandler, err = handler.New(bbsClient, metricsProvider, nil, nil, nil, handler.ConcurrencyLimits{MaxInFlight: 0}, 15, 0, lagertest.NewTestLogger("test"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("counts the rejection", func() {
+			Expect(serve("/v1/cells/some-cell/actual_lrps", nil).Code).To(Equal(http.StatusServiceUnavailable))
+
+			metrics := scrape()
+			Expect(metrics).To(MatchRegexp(`tps_listener_rejected_requests_total\{route="CellLRPs"\} [1-9]\d*`))
+			Expect(metrics).To(MatchRegexp(`tps_listener_requests_total\{code="503",route="CellLRPs"\} [1-9]\d*`))
+		})
+	})
+})
@@ -0,0 +1,153 @@
+package lrpevents
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/lrpstatus"
+	"code.cloudfoundry.org/tps/handler/requestid"
+	"github.com/vito/go-sse/sse"
+)
+
+type handler struct {
+	bbsClient bbs.Client
+	clock     clock.Clock
+	logger    lager.Logger
+}
+
+func NewHandler(bbsClient bbs.Client, clk clock.Clock, logger lager.Logger) http.Handler {
+	return &handler{
+		bbsClient: bbsClient,
+		clock:     clk,
+		logger:    logger,
+	}
+}
+
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	guid := r.FormValue(":guid")
+	if guid == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	logger := handler.logger.Session("lrp-events", requestid.Data(r, lager.Data{"process-guid": guid}))
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logger.Error("streaming-unsupported", nil)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("subscribing-to-events")
+	eventSource, err := handler.bbsClient.SubscribeToEvents(logger)
+	if err != nil {
+		logger.Error("failed-subscribing-to-events", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-r.Context().Done():
+			logger.Info("client-disconnected")
+		case <-done:
+		}
+
+		err := eventSource.Close()
+		if err != nil {
+			logger.Error("failed-closing-event-source", err)
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	logger.Info("streaming")
+	defer logger.Info("done-streaming")
+
+	eventID := 0
+	for {
+		event, err := eventSource.Next()
+		switch err {
+		case nil:
+		case events.ErrUnrecognizedEventType:
+			logger.Debug("received-unrecognized-event-type")
+			continue
+		case events.ErrSourceClosed:
+			logger.Info("event-source-closed")
+			return
+		default:
+			logger.Error("failed-getting-next-event", err)
+			return
+		}
+
+		actualLRPGroup := actualLRPGroupFor(event)
+		if actualLRPGroup == nil {
+			continue
+		}
+
+		actual, _ := actualLRPGroup.Resolve()
+		if actual.ProcessGuid != guid {
+			continue
+		}
+
+		instances := lrpstatus.LRPInstances([]*models.ActualLRPGroup{actualLRPGroup},
+			func(instance *cc_messages.LRPInstance, actual *models.ActualLRP) {
+				instance.Details = actual.PlacementError
+			},
+			handler.clock,
+		)
+
+		payload, err := json.Marshal(instances[0])
+		if err != nil {
+			logger.Error("failed-marshaling-instance", err)
+			continue
+		}
+
+		err = sse.Event{
+			ID:   strconv.Itoa(eventID),
+			Name: event.EventType(),
+			Data: payload,
+		}.Write(w)
+		if err != nil {
+			logger.Error("failed-writing-event", err)
+			return
+		}
+
+		flusher.Flush()
+		eventID++
+	}
+}
+
+func actualLRPGroupFor(event models.Event) *models.ActualLRPGroup {
+	var group *models.ActualLRPGroup
+
+	switch event := event.(type) {
+	case *models.ActualLRPCreatedEvent:
+		group = event.ActualLrpGroup
+	case *models.ActualLRPChangedEvent:
+		group = event.After
+	case *models.ActualLRPRemovedEvent:
+		group = event.ActualLrpGroup
+	}
+
+	if group == nil || (group.Instance == nil && group.Evacuating == nil) {
+		return nil
+	}
+
+	return group
+}
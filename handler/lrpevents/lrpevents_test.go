@@ -0,0 +1,130 @@
+package lrpevents_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/events/eventfakes"
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/models/test/model_helpers"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/lrpevents"
+	"github.com/vito/go-sse/sse"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("LRPEvents", func() {
+	const guid = "my-guid"
+
+	var (
+		bbsClient   *fake_bbs.FakeClient
+		eventSource *eventfakes.FakeEventSource
+		eventChan   chan models.Event
+		closed      chan struct{}
+		logger      *lagertest.TestLogger
+		server      *httptest.Server
+	)
+
+	BeforeEach(func() {
+		bbsClient = new(fake_bbs.FakeClient)
+		eventSource = new(eventfakes.FakeEventSource)
+		eventChan = make(chan models.Event, 10)
+		closed = make(chan struct{})
+		logger = lagertest.NewTestLogger("test")
+
+		eventSource.NextStub = func() (models.Event, error) {
+			select {
+			case event := <-eventChan:
+				return event, nil
+			case <-closed:
+				return nil, events.ErrSourceClosed
+			}
+		}
+
+		eventSource.CloseStub = func() error {
+			close(closed)
+			return nil
+		}
+
+		bbsClient.SubscribeToEventsReturns(eventSource, nil)
+
+		fakeClock := fakeclock.NewFakeClock(time.Now())
+		handler := lrpevents.NewHandler(bbsClient, fakeClock, logger)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Form = url.Values{":guid": []string{guid}}
+			handler.ServeHTTP(w, r)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when subscribing succeeds", func() {
+		var reader *sse.ReadCloser
+
+		BeforeEach(func() {
+			res, err := http.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+			Expect(res.Header.Get("Content-Type")).To(ContainSubstring("text/event-stream"))
+
+			reader = sse.NewReadCloser(res.Body)
+		})
+
+		It("streams changes to instances of the requested process guid", func() {
+			other := model_helpers.NewValidActualLRP("other-guid", 0)
+			eventChan <- models.NewActualLRPCreatedEvent(&models.ActualLRPGroup{Instance: other})
+
+			before := model_helpers.NewValidActualLRP(guid, 1)
+			before.State = models.ActualLRPStateClaimed
+			after := model_helpers.NewValidActualLRP(guid, 1)
+			after.State = models.ActualLRPStateRunning
+			eventChan <- models.NewActualLRPChangedEvent(
+				&models.ActualLRPGroup{Instance: before},
+				&models.ActualLRPGroup{Instance: after},
+			)
+
+			event, err := reader.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(event.Name).To(Equal(models.EventTypeActualLRPChanged))
+
+			var instance cc_messages.LRPInstance
+			err = json.Unmarshal(event.Data, &instance)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(instance.ProcessGuid).To(Equal(guid))
+			Expect(instance.Index).To(BeEquivalentTo(1))
+			Expect(instance.State).To(Equal(cc_messages.LRPInstanceStateRunning))
+		})
+
+		It("closes the event source when the client disconnects", func() {
+			reader.Close()
+			Eventually(eventSource.CloseCallCount).Should(Equal(1))
+			Eventually(logger).Should(Say("done-streaming"))
+		})
+	})
+
+	Context("when subscribing fails", func() {
+		BeforeEach(func() {
+			bbsClient.SubscribeToEventsReturns(nil, errors.New("boom"))
+		})
+
+		It("responds with a 500", func() {
+			res, err := http.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res.StatusCode).To(Equal(http.StatusInternalServerError))
+			Expect(logger).To(Say("failed-subscribing-to-events"))
+		})
+	})
+})
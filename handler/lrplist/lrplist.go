@@ -0,0 +1,206 @@
+package lrplist
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/lrpstatus"
+	"code.cloudfoundry.org/tps/handler/requestid"
+)
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 5000
+)
+
+var ErrMissingDomain = errors.New("domain is required")
+var ErrInvalidPageSize = errors.New("invalid page size")
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+type ActualLRPList struct {
+	ActualLRPs    map[string][]cc_messages.LRPInstance `json:"actual_lrps"`
+	NextPageToken string                               `json:"next_page_token,omitempty"`
+}
+
+type pageToken struct {
+	ProcessGuid string `json:"process_guid"`
+	Index       int32  `json:"index"`
+}
+
+type handler struct {
+	bbsClient bbs.Client
+	clock     clock.Clock
+	logger    lager.Logger
+}
+
+// NewHandler lists the actual LRPs of one domain a page at a time, ordered by
+// process guid and index. The page token is the last process guid and index
+// listed, so pages stay stable as instances come and go.
+func NewHandler(bbsClient bbs.Client, clk clock.Clock, logger lager.Logger) http.Handler {
+	return &handler{
+		bbsClient: bbsClient,
+		clock:     clk,
+		logger:    logger,
+	}
+}
+
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	domain := r.FormValue("domain")
+	logger := handler.logger.Session("list-actual-lrps", requestid.Data(r, lager.Data{"domain": domain}))
+
+	if domain == "" {
+		logger.Error("missing-domain", ErrMissingDomain)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	pageSize, err := parsePageSize(r.FormValue("page_size"))
+	if err != nil {
+		logger.Error("failed-parsing-page-size", err, lager.Data{"page-size": r.FormValue("page_size")})
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	after, err := decodePageToken(r.FormValue("page_token"))
+	if err != nil {
+		logger.Error("failed-parsing-page-token", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	logger.Info("fetching-actual-lrps")
+	actualLRPGroups, err := handler.bbsClient.ActualLRPGroups(logger, models.ActualLRPFilter{Domain: domain})
+	if err != nil {
+		logger.Error("fetching-actual-lrps-failed", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	page, next := paginate(actualLRPGroups, after, pageSize)
+
+	instances := lrpstatus.LRPInstances(page,
+		func(instance *cc_messages.LRPInstance, actual *models.ActualLRP) {
+			instance.Details = actual.PlacementError
+		},
+		handler.clock,
+	)
+
+	list := ActualLRPList{
+		ActualLRPs: make(map[string][]cc_messages.LRPInstance),
+	}
+	for _, instance := range instances {
+		list.ActualLRPs[instance.ProcessGuid] = append(list.ActualLRPs[instance.ProcessGuid], instance)
+	}
+
+	if next != nil {
+		list.NextPageToken = encodePageToken(*next)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(list)
+	if err != nil {
+		logger.Error("stream-response-failed", err)
+	}
+}
+
+func paginate(groups []*models.ActualLRPGroup, after *pageToken, pageSize int) ([]*models.ActualLRPGroup, *pageToken) {
+	keyed := make([]keyedGroup, 0, len(groups))
+	for _, group := range groups {
+		if group == nil || (group.Instance == nil && group.Evacuating == nil) {
+			continue
+		}
+
+		actual, _ := group.Resolve()
+		key := pageToken{ProcessGuid: actual.ProcessGuid, Index: actual.Index}
+		if after != nil && !after.less(key) {
+			continue
+		}
+
+		keyed = append(keyed, keyedGroup{key: key, group: group})
+	}
+
+	sort.Sort(byKey(keyed))
+
+	end := pageSize
+	if end > len(keyed) {
+		end = len(keyed)
+	}
+
+	page := make([]*models.ActualLRPGroup, 0, end)
+	for _, k := range keyed[:end] {
+		page = append(page, k.group)
+	}
+
+	if end < len(keyed) {
+		last := keyed[end-1].key
+		return page, &last
+	}
+
+	return page, nil
+}
+
+type keyedGroup struct {
+	key   pageToken
+	group *models.ActualLRPGroup
+}
+
+type byKey []keyedGroup
+
+func (k byKey) Len() int           { return len(k) }
+func (k byKey) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k byKey) Less(i, j int) bool { return k[i].key.less(k[j].key) }
+
+func (t pageToken) less(other pageToken) bool {
+	if t.ProcessGuid != other.ProcessGuid {
+		return t.ProcessGuid < other.ProcessGuid
+	}
+	return t.Index < other.Index
+}
+
+func parsePageSize(value string) (int, error) {
+	if value == "" {
+		return DefaultPageSize, nil
+	}
+
+	pageSize, err := strconv.Atoi(value)
+	if err != nil || pageSize < 1 || pageSize > MaxPageSize {
+		return 0, ErrInvalidPageSize
+	}
+
+	return pageSize, nil
+}
+
+func encodePageToken(token pageToken) string {
+	payload, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodePageToken(value string) (*pageToken, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	token := &pageToken{}
+	err = json.Unmarshal(payload, token)
+	if err != nil || token.ProcessGuid == "" {
+		return nil, ErrInvalidPageToken
+	}
+
+	return token, nil
+}
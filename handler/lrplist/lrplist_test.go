@@ -0,0 +1,183 @@
+package lrplist_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/tps/handler/lrplist"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LRPList", func() {
+	var (
+		handler   http.Handler
+		bbsClient *fake_bbs.FakeClient
+		query     url.Values
+	)
+
+	makeGroup := func(guid string, index int32) *models.ActualLRPGroup {
+		return &models.ActualLRPGroup{Instance: &models.ActualLRP{
+			ActualLRPKey: models.NewActualLRPKey(guid, index, "cf-apps"),
+			State:        models.ActualLRPStateRunning,
+		}}
+	}
+
+	list := func() (*httptest.ResponseRecorder, lrplist.ActualLRPList) {
+		request, err := http.NewRequest("GET", "/v1/actual_lrps?"+query.Encode(), nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+
+		var body lrplist.ActualLRPList
+		if response.Code == http.StatusOK {
+			err = json.Unmarshal(response.Body.Bytes(), &body)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		return response, body
+	}
+
+	BeforeEach(func() {
+		bbsClient = new(fake_bbs.FakeClient)
+		fakeClock := fakeclock.NewFakeClock(time.Now())
+		handler = lrplist.NewHandler(bbsClient, fakeClock, lagertest.NewTestLogger("test"))
+
+		query = url.Values{}
+		query.Set("domain", "cf-apps")
+
+		bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{
+			makeGroup("guid-c", 1),
+			makeGroup("guid-a", 1),
+			makeGroup("guid-b", 0),
+			{},
+			makeGroup("guid-c", 0),
+			makeGroup("guid-a", 0),
+		}, nil)
+	})
+
+	It("filters by domain", func() {
+		response, _ := list()
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		Expect(bbsClient.ActualLRPGroupsCallCount()).To(Equal(1))
+		_, filter := bbsClient.ActualLRPGroupsArgsForCall(0)
+		Expect(filter).To(Equal(models.ActualLRPFilter{Domain: "cf-apps"}))
+	})
+
+	Context("without a domain", func() {
+		BeforeEach(func() {
+			query.Del("domain")
+		})
+
+		It("responds with a 400", func() {
+			response, _ := list()
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(bbsClient.ActualLRPGroupsCallCount()).To(Equal(0))
+		})
+	})
+
+	It("returns every instance grouped by process guid when it fits in a page", func() {
+		response, body := list()
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		Expect(body.NextPageToken).To(BeEmpty())
+		Expect(body.ActualLRPs).To(HaveLen(3))
+		Expect(body.ActualLRPs["guid-a"]).To(HaveLen(2))
+		Expect(body.ActualLRPs["guid-b"]).To(HaveLen(1))
+		Expect(body.ActualLRPs["guid-c"]).To(HaveLen(2))
+	})
+
+	It("pages through the instances in a stable order", func() {
+		query.Set("page_size", "2")
+
+		_, page1 := list()
+		Expect(page1.ActualLRPs).To(HaveLen(1))
+		Expect(page1.ActualLRPs["guid-a"]).To(HaveLen(2))
+		Expect(page1.NextPageToken).NotTo(BeEmpty())
+
+		query.Set("page_token", page1.NextPageToken)
+		_, page2 := list()
+		Expect(page2.ActualLRPs).To(HaveLen(2))
+		Expect(page2.ActualLRPs["guid-b"][0].Index).To(BeEquivalentTo(0))
+		Expect(page2.ActualLRPs["guid-c"][0].Index).To(BeEquivalentTo(0))
+		Expect(page2.NextPageToken).NotTo(BeEmpty())
+
+		query.Set("page_token", page2.NextPageToken)
+		_, page3 := list()
+		Expect(page3.ActualLRPs).To(HaveLen(1))
+		Expect(page3.ActualLRPs["guid-c"][0].Index).To(BeEquivalentTo(1))
+		Expect(page3.NextPageToken).To(BeEmpty())
+	})
+
+	Context("when instances are at or above the desired instance count", func() {
+		BeforeEach(func() {
+			bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{
+				makeGroup("guid-a", 3),
+				makeGroup("guid-a", 0),
+				makeGroup("guid-a", 1),
+				makeGroup("guid-a", 2),
+				makeGroup("guid-orphan", 0),
+			}, nil)
+		})
+
+		It("lists each of them exactly once across pages", func() {
+			query.Set("page_size", "3")
+
+			_, page1 := list()
+			Expect(page1.ActualLRPs["guid-a"]).To(HaveLen(3))
+			Expect(page1.NextPageToken).NotTo(BeEmpty())
+
+			query.Set("page_token", page1.NextPageToken)
+			_, page2 := list()
+			Expect(page2.ActualLRPs["guid-a"]).To(HaveLen(1))
+			Expect(page2.ActualLRPs["guid-a"][0].Index).To(BeEquivalentTo(3))
+			Expect(page2.ActualLRPs["guid-orphan"]).To(HaveLen(1))
+			Expect(page2.NextPageToken).To(BeEmpty())
+		})
+	})
+
+	Context("with an invalid page size", func() {
+		BeforeEach(func() {
+			query.Set("page_size", "0")
+		})
+
+		It("responds with a 400", func() {
+			response, _ := list()
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("with an invalid page token", func() {
+		BeforeEach(func() {
+			query.Set("page_token", "not a token")
+		})
+
+		It("responds with a 400", func() {
+			response, _ := list()
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Context("when fetching actualLRPs fails", func() {
+		BeforeEach(func() {
+			bbsClient.ActualLRPGroupsReturns(nil, errors.New("boom"))
+		})
+
+		It("responds with a 500", func() {
+			response, _ := list()
+			Expect(response.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
@@ -8,7 +8,7 @@ import (
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
-type FakeNoaaClient struct {
+type FakeMetricsProvider struct {
 	ContainerMetricsStub        func(appGuid string, authToken string) ([]*events.ContainerMetric, error)
 	containerMetricsMutex       sync.RWMutex
 	containerMetricsArgsForCall []struct {
@@ -27,7 +27,7 @@ type FakeNoaaClient struct {
 	}
 }
 
-func (fake *FakeNoaaClient) ContainerMetrics(appGuid string, authToken string) ([]*events.ContainerMetric, error) {
+func (fake *FakeMetricsProvider) ContainerMetrics(appGuid string, authToken string) ([]*events.ContainerMetric, error) {
 	fake.containerMetricsMutex.Lock()
 	fake.containerMetricsArgsForCall = append(fake.containerMetricsArgsForCall, struct {
 		appGuid   string
@@ -41,19 +41,19 @@ func (fake *FakeNoaaClient) ContainerMetrics(appGuid string, authToken string) (
 	}
 }
 
-func (fake *FakeNoaaClient) ContainerMetricsCallCount() int {
+func (fake *FakeMetricsProvider) ContainerMetricsCallCount() int {
 	fake.containerMetricsMutex.RLock()
 	defer fake.containerMetricsMutex.RUnlock()
 	return len(fake.containerMetricsArgsForCall)
 }
 
-func (fake *FakeNoaaClient) ContainerMetricsArgsForCall(i int) (string, string) {
+func (fake *FakeMetricsProvider) ContainerMetricsArgsForCall(i int) (string, string) {
 	fake.containerMetricsMutex.RLock()
 	defer fake.containerMetricsMutex.RUnlock()
 	return fake.containerMetricsArgsForCall[i].appGuid, fake.containerMetricsArgsForCall[i].authToken
 }
 
-func (fake *FakeNoaaClient) ContainerMetricsReturns(result1 []*events.ContainerMetric, result2 error) {
+func (fake *FakeMetricsProvider) ContainerMetricsReturns(result1 []*events.ContainerMetric, result2 error) {
 	fake.ContainerMetricsStub = nil
 	fake.containerMetricsReturns = struct {
 		result1 []*events.ContainerMetric
@@ -61,7 +61,7 @@ func (fake *FakeNoaaClient) ContainerMetricsReturns(result1 []*events.ContainerM
 	}{result1, result2}
 }
 
-func (fake *FakeNoaaClient) Close() error {
+func (fake *FakeMetricsProvider) Close() error {
 	fake.closeMutex.Lock()
 	fake.closeArgsForCall = append(fake.closeArgsForCall, struct{}{})
 	fake.closeMutex.Unlock()
@@ -72,17 +72,17 @@ func (fake *FakeNoaaClient) Close() error {
 	}
 }
 
-func (fake *FakeNoaaClient) CloseCallCount() int {
+func (fake *FakeMetricsProvider) CloseCallCount() int {
 	fake.closeMutex.RLock()
 	defer fake.closeMutex.RUnlock()
 	return len(fake.closeArgsForCall)
 }
 
-func (fake *FakeNoaaClient) CloseReturns(result1 error) {
+func (fake *FakeMetricsProvider) CloseReturns(result1 error) {
 	fake.CloseStub = nil
 	fake.closeReturns = struct {
 		result1 error
 	}{result1}
 }
 
-var _ lrpstats.NoaaClient = new(FakeNoaaClient)
+var _ lrpstats.MetricsProvider = new(FakeMetricsProvider)
@@ -3,6 +3,7 @@ package lrpstats
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/bbs/models"
@@ -11,24 +12,55 @@ import (
 	"code.cloudfoundry.org/nsync/recipebuilder"
 	"code.cloudfoundry.org/runtimeschema/cc_messages"
 	"code.cloudfoundry.org/tps/handler/lrpstatus"
+	"code.cloudfoundry.org/tps/handler/metricshistory"
+	"code.cloudfoundry.org/tps/handler/requestid"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
-//go:generate counterfeiter -o fakes/fake_noaaclient.go . NoaaClient
-type NoaaClient interface {
+//go:generate counterfeiter -o fakes/fake_metrics_provider.go . MetricsProvider
+type MetricsProvider interface {
 	ContainerMetrics(appGuid string, authToken string) ([]*events.ContainerMetric, error)
 	Close() error
 }
 
+type LRPInstance struct {
+	cc_messages.LRPInstance
+	Stats  *LRPInstanceStats `json:"stats,omitempty"`
+	Ports  []PortMapping     `json:"ports"`
+	Routes *Routes           `json:"routes,omitempty"`
+}
+
+type PortMapping struct {
+	ContainerPort uint32 `json:"container_port"`
+	HostPort      uint32 `json:"host_port"`
+}
+
+type LRPInstanceStats struct {
+	cc_messages.LRPInstanceStats
+	MemoryQuotaBytes uint64  `json:"mem_quota"`
+	DiskQuotaBytes   uint64  `json:"disk_quota"`
+	MemoryPercentage float64 `json:"mem_percentage"`
+	DiskPercentage   float64 `json:"disk_percentage"`
+}
+
+type LRPInstanceWithHistory struct {
+	LRPInstance
+	History []metricshistory.Sample `json:"history,omitempty"`
+	Summary *metricshistory.Summary `json:"summary,omitempty"`
+}
+
 type handler struct {
-	bbsClient  bbs.Client
-	noaaClient NoaaClient
-	clock      clock.Clock
-	logger     lager.Logger
+	bbsClient       bbs.Client
+	metricsProvider MetricsProvider
+	history         *metricshistory.History
+	validator       tokenauth.Validator
+	clock           clock.Clock
+	logger          lager.Logger
 }
 
-func NewHandler(bbsClient bbs.Client, noaaClient NoaaClient, clk clock.Clock, logger lager.Logger) http.Handler {
-	return &handler{bbsClient: bbsClient, noaaClient: noaaClient, clock: clk, logger: logger}
+func NewHandler(bbsClient bbs.Client, metricsProvider MetricsProvider, history *metricshistory.History, validator tokenauth.Validator, clk clock.Clock, logger lager.Logger) http.Handler {
+	return &handler{bbsClient: bbsClient, metricsProvider: metricsProvider, history: history, validator: validator, clock: clk, logger: logger}
 }
 
 func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -38,18 +70,35 @@ func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if handler.validator != nil {
+		err := handler.validator.Validate(authorization)
+		if err != nil {
+			handler.logger.Session("lrp-stats", requestid.Data(r, nil)).Error("invalid-token", err)
+			w.WriteHeader(tokenauth.StatusCode(err))
+			return
+		}
+	}
+
 	guid := r.FormValue(":guid")
 	if guid == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	logger := handler.logger.Session("lrp-stats", lager.Data{"process-guid": guid})
+	var window time.Duration
+	if windowParameter := r.FormValue("window"); windowParameter != "" {
+		var err error
+		window, err = time.ParseDuration(windowParameter)
+		if err != nil || window <= 0 || handler.history == nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	logger := handler.logger.Session("lrp-stats", requestid.Data(r, lager.Data{"process-guid": guid}))
 
-	logger.Info("fetching-desired-lrp")
-	desiredLRP, err := handler.bbsClient.DesiredLRPByProcessGuid(logger, guid)
+	desiredLRP, instances, err := instancesWithStats(logger, handler.bbsClient, handler.metricsProvider, handler.clock, guid, authorization)
 	if err != nil {
-		logger.Error("fetching-desired-lrp-failed", err)
 		switch models.ConvertError(err).Type {
 		case models.Error_ResourceNotFound:
 			w.WriteHeader(http.StatusNotFound)
@@ -59,26 +108,81 @@ func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if window == 0 {
+		err = json.NewEncoder(w).Encode(instances)
+	} else {
+		handler.history.Watch(desiredLRP.ProcessGuid, desiredLRP.LogGuid)
+		samples := handler.history.Samples(desiredLRP.LogGuid, window)
+		err = json.NewEncoder(w).Encode(withHistory(instances, samples, r.FormValue("summary") == "true"))
+	}
+	if err != nil {
+		logger.Error("stream-response-failed", err)
+	}
+}
+
+func withHistory(instances []LRPInstance, samples map[uint][]metricshistory.Sample, summarize bool) []LRPInstanceWithHistory {
+	withHistory := make([]LRPInstanceWithHistory, len(instances))
+	for i, instance := range instances {
+		withHistory[i].LRPInstance = instance
+		if summarize {
+			withHistory[i].Summary = metricshistory.Summarize(samples[instance.Index])
+		} else {
+			withHistory[i].History = samples[instance.Index]
+		}
+	}
+
+	return withHistory
+}
+
+func InstancesWithStats(
+	logger lager.Logger,
+	bbsClient bbs.Client,
+	metricsProvider MetricsProvider,
+	clk clock.Clock,
+	guid string,
+	authorization string,
+) ([]LRPInstance, error) {
+	_, instances, err := instancesWithStats(logger, bbsClient, metricsProvider, clk, guid, authorization)
+	return instances, err
+}
+
+func instancesWithStats(
+	logger lager.Logger,
+	bbsClient bbs.Client,
+	metricsProvider MetricsProvider,
+	clk clock.Clock,
+	guid string,
+	authorization string,
+) (*models.DesiredLRP, []LRPInstance, error) {
+	logger.Info("fetching-desired-lrp")
+	desiredLRP, err := bbsClient.DesiredLRPByProcessGuid(logger, guid)
+	if err != nil {
+		logger.Error("fetching-desired-lrp-failed", err)
+		return nil, nil, err
+	}
+
 	logger.Info("fetching-actual-lrp-info")
-	actualLRPs, err := handler.bbsClient.ActualLRPGroupsByProcessGuid(logger, guid)
+	actualLRPs, err := bbsClient.ActualLRPGroupsByProcessGuid(logger, guid)
 	if err != nil {
 		logger.Error("fetching-actual-lrp-info-failed", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, nil, err
 	}
 
 	logger.Info("fetching-container-metrics", lager.Data{
 		"log-guid": desiredLRP.LogGuid,
 	})
-	metrics, err := handler.noaaClient.ContainerMetrics(desiredLRP.LogGuid, authorization)
+	metrics, err := metricsProvider.ContainerMetrics(desiredLRP.LogGuid, authorization)
 	if err != nil {
-		handler.logger.Error("fetching-container-metrics-failed", err, lager.Data{
+		logger.Error("fetching-container-metrics-failed", err, lager.Data{
 			"log-guid": desiredLRP.LogGuid,
 		})
 	}
 
 	metricsByInstanceIndex := make(map[uint]*cc_messages.LRPInstanceStats)
-	currentTime := handler.clock.Now()
+	currentTime := clk.Now()
 	for _, metric := range metrics {
 		cpuPercentageAsDecimal := metric.GetCpuPercentage() / 100
 		metricsByInstanceIndex[uint(metric.GetInstanceIndex())] = &cc_messages.LRPInstanceStats{
@@ -89,14 +193,16 @@ func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	portsByInstanceIndex := make(map[uint][]PortMapping)
 	instances := lrpstatus.LRPInstances(actualLRPs,
 		func(instance *cc_messages.LRPInstance, actual *models.ActualLRP) {
 			instance.Host = actual.Address
 			instance.Port = getDefaultPort(actual.Ports)
+			portsByInstanceIndex[uint(actual.Index)] = portMappings(actual.Ports)
 			stats := metricsByInstanceIndex[uint(actual.Index)]
 			instance.Stats = stats
 		},
-		handler.clock,
+		clk,
 	)
 
 	for i, instance := range instances {
@@ -110,13 +216,65 @@ func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-
-	err = json.NewEncoder(w).Encode(instances)
+	routes, err := RoutesFor(desiredLRP)
 	if err != nil {
-		handler.logger.Error("stream-response-failed", err, lager.Data{"guid": guid})
+		logger.Error("decoding-routes-failed", err)
 	}
+
+	return desiredLRP, withDesiredInfo(instances, portsByInstanceIndex, routes, desiredLRP), nil
+}
+
+func withDesiredInfo(
+	instances []cc_messages.LRPInstance,
+	portsByInstanceIndex map[uint][]PortMapping,
+	routes *Routes,
+	desiredLRP *models.DesiredLRP,
+) []LRPInstance {
+	memoryQuotaBytes := uint64(desiredLRP.MemoryMb) * 1024 * 1024
+	diskQuotaBytes := uint64(desiredLRP.DiskMb) * 1024 * 1024
+
+	withDesiredInfo := make([]LRPInstance, len(instances))
+	for i, instance := range instances {
+		stats := instance.Stats
+		instance.Stats = nil
+		withDesiredInfo[i].LRPInstance = instance
+		withDesiredInfo[i].Ports = portsByInstanceIndex[instance.Index]
+		withDesiredInfo[i].Routes = routes
+
+		if stats == nil {
+			continue
+		}
+
+		withDesiredInfo[i].Stats = &LRPInstanceStats{
+			LRPInstanceStats: *stats,
+			MemoryQuotaBytes: memoryQuotaBytes,
+			DiskQuotaBytes:   diskQuotaBytes,
+			MemoryPercentage: usage(stats.MemoryBytes, memoryQuotaBytes),
+			DiskPercentage:   usage(stats.DiskBytes, diskQuotaBytes),
+		}
+	}
+
+	return withDesiredInfo
+}
+
+func usage(used, quota uint64) float64 {
+	if quota == 0 {
+		return 0
+	}
+
+	return float64(used) / float64(quota)
+}
+
+func portMappings(mappings []*models.PortMapping) []PortMapping {
+	ports := make([]PortMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		ports = append(ports, PortMapping{
+			ContainerPort: mapping.ContainerPort,
+			HostPort:      mapping.HostPort,
+		})
+	}
+
+	return ports
 }
 
 func getDefaultPort(mappings []*models.PortMapping) uint16 {
@@ -16,6 +16,9 @@ import (
 	"code.cloudfoundry.org/runtimeschema/cc_messages"
 	"code.cloudfoundry.org/tps/handler/lrpstats"
 	"code.cloudfoundry.org/tps/handler/lrpstats/fakes"
+	"code.cloudfoundry.org/tps/handler/metricshistory"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
+	tokenauthfakes "code.cloudfoundry.org/tps/handler/tokenauth/fakes"
 	"github.com/cloudfoundry/sonde-go/events"
 	"github.com/gogo/protobuf/proto"
 
@@ -30,23 +33,27 @@ var _ = Describe("Stats", func() {
 	const logGuid = "log-guid"
 
 	var (
-		handler    http.Handler
-		response   *httptest.ResponseRecorder
-		request    *http.Request
-		noaaClient *fakes.FakeNoaaClient
-		bbsClient  *fake_bbs.FakeClient
-		logger     *lagertest.TestLogger
-		fakeClock  *fakeclock.FakeClock
+		handler         http.Handler
+		response        *httptest.ResponseRecorder
+		request         *http.Request
+		metricsProvider *fakes.FakeMetricsProvider
+		bbsClient       *fake_bbs.FakeClient
+		logger          *lagertest.TestLogger
+		fakeClock       *fakeclock.FakeClock
+		history         *metricshistory.History
+		validator       *tokenauthfakes.FakeValidator
 	)
 
 	BeforeEach(func() {
 		var err error
 
 		bbsClient = new(fake_bbs.FakeClient)
-		noaaClient = &fakes.FakeNoaaClient{}
+		metricsProvider = &fakes.FakeMetricsProvider{}
 		logger = lagertest.NewTestLogger("test")
 		fakeClock = fakeclock.NewFakeClock(time.Date(2008, 8, 8, 8, 8, 8, 8, time.UTC))
-		handler = lrpstats.NewHandler(bbsClient, noaaClient, fakeClock, logger)
+		history = metricshistory.New(fakeClock, 10, 10, time.Hour)
+		validator = &tokenauthfakes.FakeValidator{}
+		handler = lrpstats.NewHandler(bbsClient, metricsProvider, history, validator, fakeClock, logger)
 		response = httptest.NewRecorder()
 		request, err = http.NewRequest("GET", "/v1/actual_lrps/:guid/stats", nil)
 		Expect(err).NotTo(HaveOccurred())
@@ -69,6 +76,51 @@ var _ = Describe("Stats", func() {
 			It("fails with no guid", func() {
 				Expect(response.Code).To(Equal(http.StatusBadRequest))
 			})
+
+			It("validates the token", func() {
+				Expect(validator.ValidateCallCount()).To(Equal(1))
+				Expect(validator.ValidateArgsForCall(0)).To(Equal(authorization))
+			})
+
+			Context("when the token is invalid", func() {
+				BeforeEach(func() {
+					request.Form = url.Values{}
+					request.Form.Add(":guid", guid)
+					validator.ValidateReturns(tokenauth.ErrTokenExpired)
+				})
+
+				It("fails with a 401 without contacting the BBS", func() {
+					Expect(response.Code).To(Equal(http.StatusUnauthorized))
+					Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(0))
+					Expect(bbsClient.ActualLRPGroupsByProcessGuidCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the token lacks a required scope", func() {
+				BeforeEach(func() {
+					request.Form = url.Values{}
+					request.Form.Add(":guid", guid)
+					validator.ValidateReturns(tokenauth.ErrInsufficientScope)
+				})
+
+				It("fails with a 403 without contacting the BBS", func() {
+					Expect(response.Code).To(Equal(http.StatusForbidden))
+					Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the signing keys cannot be fetched", func() {
+				BeforeEach(func() {
+					request.Form = url.Values{}
+					request.Form.Add(":guid", guid)
+					validator.ValidateReturns(&tokenauth.KeySetUnavailableError{Err: errors.New("connection refused")})
+				})
+
+				It("fails with a 503 without contacting the BBS", func() {
+					Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+					Expect(bbsClient.DesiredLRPByProcessGuidCallCount()).To(Equal(0))
+				})
+			})
 		})
 	})
 
@@ -81,7 +133,7 @@ var _ = Describe("Stats", func() {
 			request.Form = url.Values{}
 			request.Form.Add(":guid", guid)
 
-			noaaClient.ContainerMetricsReturns([]*events.ContainerMetric{
+			metricsProvider.ContainerMetricsReturns([]*events.ContainerMetric{
 				{
 					ApplicationId: proto.String("appId"),
 					InstanceIndex: proto.Int32(5),
@@ -192,9 +244,205 @@ var _ = Describe("Stats", func() {
 			})
 		})
 
+		Context("when the desired LRP has memory and disk quotas", func() {
+			BeforeEach(func() {
+				bbsClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{
+					LogGuid:     logGuid,
+					ProcessGuid: guid,
+					MemoryMb:    1,
+					DiskMb:      2,
+				}, nil)
+			})
+
+			It("returns the quotas and the fraction of each used", func() {
+				var stats []lrpstats.LRPInstance
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				err := json.Unmarshal(response.Body.Bytes(), &stats)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stats).To(HaveLen(1))
+				Expect(stats[0].Stats).NotTo(BeNil())
+				Expect(stats[0].Stats.MemoryBytes).To(BeEquivalentTo(1024))
+				Expect(stats[0].Stats.MemoryQuotaBytes).To(BeEquivalentTo(1024 * 1024))
+				Expect(stats[0].Stats.DiskQuotaBytes).To(BeEquivalentTo(2 * 1024 * 1024))
+				Expect(stats[0].Stats.MemoryPercentage).To(Equal(1024.0 / (1024 * 1024)))
+				Expect(stats[0].Stats.DiskPercentage).To(Equal(2048.0 / (2 * 1024 * 1024)))
+			})
+
+			Context("when the LRP has crashed", func() {
+				BeforeEach(func() {
+					actualLRP.State = models.ActualLRPStateCrashed
+				})
+
+				It("reports the quotas with no usage", func() {
+					var stats []lrpstats.LRPInstance
+
+					err := json.Unmarshal(response.Body.Bytes(), &stats)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(stats[0].Stats.MemoryQuotaBytes).To(BeEquivalentTo(1024 * 1024))
+					Expect(stats[0].Stats.MemoryPercentage).To(BeZero())
+					Expect(stats[0].Stats.DiskPercentage).To(BeZero())
+				})
+			})
+		})
+
+		Context("when the desired LRP has no quotas", func() {
+			It("reports zero usage rather than dividing by zero", func() {
+				var stats []lrpstats.LRPInstance
+
+				err := json.Unmarshal(response.Body.Bytes(), &stats)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stats[0].Stats.MemoryQuotaBytes).To(BeZero())
+				Expect(stats[0].Stats.MemoryPercentage).To(BeZero())
+			})
+		})
+
+		It("returns every port mapping of the instance", func() {
+			var stats []lrpstats.LRPInstance
+
+			err := json.Unmarshal(response.Body.Bytes(), &stats)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(stats).To(HaveLen(1))
+			Expect(stats[0].Port).To(BeEquivalentTo(1234))
+			Expect(stats[0].Ports).To(ConsistOf(
+				lrpstats.PortMapping{ContainerPort: 7890, HostPort: 5432},
+				lrpstats.PortMapping{ContainerPort: uint32(recipebuilder.DefaultPort), HostPort: 1234},
+			))
+			Expect(stats[0].Routes).To(BeNil())
+		})
+
+		Context("when the desired LRP has routes", func() {
+			BeforeEach(func() {
+				httpRoutes := json.RawMessage(`[{"hostnames":["app.example.com"],"port":7890}]`)
+				tcpRoutes := json.RawMessage(`[{"router_group_guid":"group","external_port":61000,"container_port":5432}]`)
+
+				bbsClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{
+					LogGuid:     logGuid,
+					ProcessGuid: guid,
+					Routes: &models.Routes{
+						lrpstats.CFRouter:  &httpRoutes,
+						lrpstats.TCPRouter: &tcpRoutes,
+					},
+				}, nil)
+			})
+
+			It("returns the http and tcp routes", func() {
+				var stats []lrpstats.LRPInstance
+
+				err := json.Unmarshal(response.Body.Bytes(), &stats)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stats[0].Routes).To(Equal(&lrpstats.Routes{
+					HTTP: []lrpstats.HTTPRoute{{Hostnames: []string{"app.example.com"}, Port: 7890}},
+					TCP:  []lrpstats.TCPRoute{{RouterGroupGuid: "group", ExternalPort: 61000, ContainerPort: 5432}},
+				}))
+			})
+
+			Context("when the routes cannot be decoded", func() {
+				BeforeEach(func() {
+					badRoutes := json.RawMessage(`{"not":"a list"}`)
+
+					bbsClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{
+						LogGuid:     logGuid,
+						ProcessGuid: guid,
+						Routes:      &models.Routes{lrpstats.CFRouter: &badRoutes},
+					}, nil)
+				})
+
+				It("still returns the stats without routes", func() {
+					var stats []lrpstats.LRPInstance
+
+					Expect(response.Code).To(Equal(http.StatusOK))
+					err := json.Unmarshal(response.Body.Bytes(), &stats)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(stats[0].Routes).To(BeNil())
+					Expect(stats[0].Ports).To(HaveLen(2))
+					Expect(logger).To(Say("decoding-routes-failed"))
+				})
+			})
+		})
+
+		Context("when a metrics window is requested", func() {
+			BeforeEach(func() {
+				request.Form.Add("window", "5m")
+				history.Watch(guid, logGuid)
+				history.Record(logGuid, 5, metricshistory.Sample{
+					Time:          fakeClock.Now().Add(-time.Minute),
+					CpuPercentage: 0.04,
+					MemoryBytes:   1024,
+					DiskBytes:     2048,
+				})
+			})
+
+			It("returns the recorded metrics history per instance", func() {
+				var stats []lrpstats.LRPInstanceWithHistory
+
+				Expect(response.Code).To(Equal(http.StatusOK))
+				err := json.Unmarshal(response.Body.Bytes(), &stats)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(stats).To(HaveLen(1))
+				Expect(stats[0].Stats).NotTo(BeNil())
+				Expect(stats[0].History).To(HaveLen(1))
+				Expect(stats[0].History[0].CpuPercentage).To(Equal(0.04))
+				Expect(stats[0].History[0].MemoryBytes).To(BeEquivalentTo(1024))
+				Expect(stats[0].Summary).To(BeNil())
+			})
+
+			Context("when a summary is requested", func() {
+				BeforeEach(func() {
+					request.Form.Add("summary", "true")
+				})
+
+				It("returns min, avg and max per instance", func() {
+					var stats []lrpstats.LRPInstanceWithHistory
+
+					Expect(response.Code).To(Equal(http.StatusOK))
+					err := json.Unmarshal(response.Body.Bytes(), &stats)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(stats).To(HaveLen(1))
+					Expect(stats[0].History).To(BeEmpty())
+					Expect(stats[0].Summary).NotTo(BeNil())
+					Expect(stats[0].Summary.Samples).To(Equal(1))
+					Expect(stats[0].Summary.MemoryBytes).To(Equal(metricshistory.Range{Min: 1024, Avg: 1024, Max: 1024}))
+				})
+			})
+
+			Context("when the window is invalid", func() {
+				BeforeEach(func() {
+					request.Form.Set("window", "forever")
+				})
+
+				It("responds with a 400", func() {
+					Expect(response.Code).To(Equal(http.StatusBadRequest))
+				})
+			})
+		})
+
+		Context("when a metrics window is first requested", func() {
+			BeforeEach(func() {
+				request.Form.Add("window", "5m")
+			})
+
+			It("starts watching the app so that its history is sampled", func() {
+				Expect(response.Code).To(Equal(http.StatusOK))
+				Expect(history.Watched()).To(ConsistOf(metricshistory.Watched{ProcessGuid: guid, LogGuid: logGuid}))
+			})
+		})
+
+		It("does not watch apps whose stats are requested without a window", func() {
+			Expect(history.Watched()).To(BeEmpty())
+		})
+
 		It("calls ContainerMetrics", func() {
-			Expect(noaaClient.ContainerMetricsCallCount()).To(Equal(1))
-			guid, token := noaaClient.ContainerMetricsArgsForCall(0)
+			Expect(metricsProvider.ContainerMetricsCallCount()).To(Equal(1))
+			guid, token := metricsProvider.ContainerMetricsArgsForCall(0)
 			Expect(guid).To(Equal(logGuid))
 			Expect(token).To(Equal(authorization))
 		})
@@ -202,7 +450,7 @@ var _ = Describe("Stats", func() {
 		Context("when ContainerMetrics fails", func() {
 			var expectedLRPInstance cc_messages.LRPInstance
 			BeforeEach(func() {
-				noaaClient.ContainerMetricsReturns(nil, errors.New("bad stuff happened"))
+				metricsProvider.ContainerMetricsReturns(nil, errors.New("bad stuff happened"))
 				expectedLRPInstance = cc_messages.LRPInstance{
 					ProcessGuid:  guid,
 					InstanceGuid: "instanceId",
@@ -0,0 +1,57 @@
+package lrpstats
+
+import (
+	"encoding/json"
+
+	"code.cloudfoundry.org/bbs/models"
+)
+
+const (
+	CFRouter  = "cf-router"
+	TCPRouter = "tcp-router"
+)
+
+type Routes struct {
+	HTTP []HTTPRoute `json:"http"`
+	TCP  []TCPRoute  `json:"tcp"`
+}
+
+type HTTPRoute struct {
+	Hostnames       []string `json:"hostnames"`
+	Port            uint32   `json:"port"`
+	RouteServiceUrl string   `json:"route_service_url,omitempty"`
+}
+
+type TCPRoute struct {
+	RouterGroupGuid string `json:"router_group_guid"`
+	ExternalPort    uint32 `json:"external_port"`
+	ContainerPort   uint32 `json:"container_port"`
+}
+
+func RoutesFor(desiredLRP *models.DesiredLRP) (*Routes, error) {
+	if desiredLRP.Routes == nil {
+		return nil, nil
+	}
+
+	routes := &Routes{
+		HTTP: []HTTPRoute{},
+		TCP:  []TCPRoute{},
+	}
+
+	routingInfo := *desiredLRP.Routes
+	if data, ok := routingInfo[CFRouter]; ok && data != nil {
+		err := json.Unmarshal(*data, &routes.HTTP)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if data, ok := routingInfo[TCPRouter]; ok && data != nil {
+		err := json.Unmarshal(*data, &routes.TCP)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return routes, nil
+}
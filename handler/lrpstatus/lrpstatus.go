@@ -9,10 +9,18 @@ import (
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/tps/handler/cc_conv"
+	"code.cloudfoundry.org/tps/handler/etag"
+	"code.cloudfoundry.org/tps/handler/requestid"
 
 	"code.cloudfoundry.org/runtimeschema/cc_messages"
 )
 
+type EvacuationAwareLRPInstance struct {
+	cc_messages.LRPInstance
+	CellID     string `json:"cell_id"`
+	Evacuating bool   `json:"evacuating"`
+}
+
 type handler struct {
 	apiClient bbs.Client
 	clock     clock.Clock
@@ -29,7 +37,7 @@ func NewHandler(apiClient bbs.Client, clk clock.Clock, logger lager.Logger) http
 
 func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	guid := r.FormValue(":guid")
-	logger := handler.logger.Session("lrp-status", lager.Data{"process-guid": guid})
+	logger := handler.logger.Session("lrp-status", requestid.Data(r, lager.Data{"process-guid": guid}))
 
 	logger.Info("fetching-actual-lrp-info")
 	actualLRPGroups, err := handler.apiClient.ActualLRPGroupsByProcessGuid(logger, guid)
@@ -39,14 +47,36 @@ func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	instances := LRPInstances(actualLRPGroups,
-		func(instance *cc_messages.LRPInstance, actual *models.ActualLRP) {
-			instance.Details = actual.PlacementError
-		},
-		handler.clock,
-	)
+	addDetails := func(instance *cc_messages.LRPInstance, actual *models.ActualLRP) {
+		instance.Details = actual.PlacementError
+	}
+
+	var response, fingerprint interface{}
+	if r.FormValue("include_evacuating") == "true" {
+		instances := EvacuationAwareLRPInstances(actualLRPGroups, addDetails, handler.clock)
+		response = instances
+
+		stripped := make([]EvacuationAwareLRPInstance, len(instances))
+		for i, instance := range instances {
+			instance.Uptime = 0
+			stripped[i] = instance
+		}
+		fingerprint = stripped
+	} else {
+		instances := LRPInstances(actualLRPGroups, addDetails, handler.clock)
+		response = instances
+		fingerprint = etag.WithoutUptime(instances)
+	}
+
+	notModified, err := etag.CheckNotModified(w, r, fingerprint)
+	if err != nil {
+		logger.Error("failed-computing-etag", err)
+	}
+	if notModified {
+		return
+	}
 
-	err = json.NewEncoder(w).Encode(instances)
+	err = json.NewEncoder(w).Encode(response)
 	if err != nil {
 		logger.Error("stream-response-failed", err)
 	}
@@ -60,23 +90,56 @@ func LRPInstances(
 	instances := make([]cc_messages.LRPInstance, len(actualLRPGroups))
 	for i, actualLRPGroup := range actualLRPGroups {
 		actual, _ := actualLRPGroup.Resolve()
+		instances[i] = lrpInstance(actual, addInfo, clk)
+	}
 
-		instance := cc_messages.LRPInstance{
-			ProcessGuid:  actual.ProcessGuid,
-			InstanceGuid: actual.InstanceGuid,
-			Index:        uint(actual.Index),
-			Since:        actual.Since / 1e9,
-			Uptime:       (clk.Now().UnixNano() - actual.Since) / 1e9,
-			State:        cc_conv.StateFor(actual.State, actual.PlacementError),
-			NetInfo:      actual.ActualLRPNetInfo,
-		}
+	return instances
+}
 
-		if addInfo != nil {
-			addInfo(&instance, actual)
+func EvacuationAwareLRPInstances(
+	actualLRPGroups []*models.ActualLRPGroup,
+	addInfo func(*cc_messages.LRPInstance, *models.ActualLRP),
+	clk clock.Clock,
+) []EvacuationAwareLRPInstance {
+	instances := []EvacuationAwareLRPInstance{}
+	for _, actualLRPGroup := range actualLRPGroups {
+		if actualLRPGroup.Instance != nil {
+			instances = append(instances, EvacuationAwareLRPInstance{
+				LRPInstance: lrpInstance(actualLRPGroup.Instance, addInfo, clk),
+				CellID:      actualLRPGroup.Instance.CellId,
+			})
 		}
 
-		instances[i] = instance
+		if actualLRPGroup.Evacuating != nil {
+			instances = append(instances, EvacuationAwareLRPInstance{
+				LRPInstance: lrpInstance(actualLRPGroup.Evacuating, addInfo, clk),
+				CellID:      actualLRPGroup.Evacuating.CellId,
+				Evacuating:  true,
+			})
+		}
 	}
 
 	return instances
 }
+
+func lrpInstance(
+	actual *models.ActualLRP,
+	addInfo func(*cc_messages.LRPInstance, *models.ActualLRP),
+	clk clock.Clock,
+) cc_messages.LRPInstance {
+	instance := cc_messages.LRPInstance{
+		ProcessGuid:  actual.ProcessGuid,
+		InstanceGuid: actual.InstanceGuid,
+		Index:        uint(actual.Index),
+		Since:        actual.Since / 1e9,
+		Uptime:       (clk.Now().UnixNano() - actual.Since) / 1e9,
+		State:        cc_conv.StateFor(actual.State, actual.PlacementError),
+		NetInfo:      actual.ActualLRPNetInfo,
+	}
+
+	if addInfo != nil {
+		addInfo(&instance, actual)
+	}
+
+	return instance
+}
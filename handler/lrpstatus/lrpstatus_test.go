@@ -67,6 +67,118 @@ var _ = Describe("LRPStatus", func() {
 			Expect(response[3].Details).To(Equal(diego_errors.CELL_MISMATCH_MESSAGE))
 		})
 	})
+
+	Describe("Conditional requests", func() {
+		var fakeClock *fakeclock.FakeClock
+
+		BeforeEach(func() {
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			server.Close()
+			server = httptest.NewServer(lrpstatus.NewHandler(fakeClient, fakeClock, lagertest.NewTestLogger("test")))
+
+			fakeClient.ActualLRPGroupsByProcessGuidStub = func(lager.Logger, string) ([]*models.ActualLRPGroup, error) {
+				return []*models.ActualLRPGroup{
+					makeActualLRPGroup(1, models.ActualLRPStateRunning, ""),
+				}, nil
+			}
+		})
+
+		It("returns an ETag that does not change with uptime", func() {
+			res, err := http.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+			tag := res.Header.Get("ETag")
+			Expect(tag).NotTo(BeEmpty())
+
+			fakeClock.Increment(time.Minute)
+
+			res, err = http.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+			Expect(res.Header.Get("ETag")).To(Equal(tag))
+		})
+
+		It("responds with 304 Not Modified when If-None-Match matches", func() {
+			res, err := http.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+
+			request, err := http.NewRequest("GET", server.URL, nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("If-None-Match", res.Header.Get("ETag"))
+
+			res, err = http.DefaultClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusNotModified))
+		})
+
+		It("responds with the instances when the state has changed", func() {
+			request, err := http.NewRequest("GET", server.URL, nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("If-None-Match", `W/"stale"`)
+
+			res, err := http.DefaultClient.Do(request)
+			Expect(err).NotTo(HaveOccurred())
+			res.Body.Close()
+			Expect(res.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("Evacuating instances", func() {
+		BeforeEach(func() {
+			fakeClient.ActualLRPGroupsByProcessGuidStub = func(lager.Logger, string) ([]*models.ActualLRPGroup, error) {
+				instance := model_helpers.NewValidActualLRP("guid", 0)
+				instance.InstanceGuid = "new-instance-guid"
+				instance.CellId = "new-cell"
+				instance.State = models.ActualLRPStateClaimed
+
+				evacuating := model_helpers.NewValidActualLRP("guid", 0)
+				evacuating.InstanceGuid = "old-instance-guid"
+				evacuating.CellId = "old-cell"
+				evacuating.State = models.ActualLRPStateRunning
+
+				return []*models.ActualLRPGroup{{Instance: instance, Evacuating: evacuating}}, nil
+			}
+		})
+
+		It("returns only the resolved instance by default", func() {
+			res, err := http.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+
+			response := []cc_messages.LRPInstance{}
+			err = json.NewDecoder(res.Body).Decode(&response)
+			res.Body.Close()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(response).To(HaveLen(1))
+			Expect(response[0].InstanceGuid).To(Equal("old-instance-guid"))
+		})
+
+		Context("when include_evacuating is requested", func() {
+			It("returns both the instance and the evacuating instance", func() {
+				res, err := http.Get(server.URL + "?include_evacuating=true")
+				Expect(err).NotTo(HaveOccurred())
+
+				response := []lrpstatus.EvacuationAwareLRPInstance{}
+				err = json.NewDecoder(res.Body).Decode(&response)
+				res.Body.Close()
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(response).To(HaveLen(2))
+
+				Expect(response[0].InstanceGuid).To(Equal("new-instance-guid"))
+				Expect(response[0].CellID).To(Equal("new-cell"))
+				Expect(response[0].Evacuating).To(BeFalse())
+				Expect(response[0].State).To(Equal(cc_messages.LRPInstanceStateStarting))
+
+				Expect(response[1].InstanceGuid).To(Equal("old-instance-guid"))
+				Expect(response[1].CellID).To(Equal("old-cell"))
+				Expect(response[1].Evacuating).To(BeTrue())
+				Expect(response[1].State).To(Equal(cc_messages.LRPInstanceStateRunning))
+			})
+		})
+	})
 })
 
 func makeActualLRPGroup(index int32, state string, placementError string) *models.ActualLRPGroup {
@@ -0,0 +1,106 @@
+package lrpsummary
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/lrpstatus"
+	"code.cloudfoundry.org/tps/handler/requestid"
+)
+
+type LRPSummary struct {
+	ProcessGuid      string                               `json:"process_guid"`
+	DesiredInstances int32                                `json:"desired_instances"`
+	ActualInstances  int                                  `json:"actual_instances"`
+	States           map[cc_messages.LRPInstanceState]int `json:"states"`
+	MissingIndices   []int32                              `json:"missing_indices"`
+	OldestSince      int64                                `json:"oldest_since,omitempty"`
+}
+
+type handler struct {
+	bbsClient bbs.Client
+	clock     clock.Clock
+	logger    lager.Logger
+}
+
+func NewHandler(bbsClient bbs.Client, clk clock.Clock, logger lager.Logger) http.Handler {
+	return &handler{
+		bbsClient: bbsClient,
+		clock:     clk,
+		logger:    logger,
+	}
+}
+
+func (handler *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	guid := r.FormValue(":guid")
+	if guid == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	logger := handler.logger.Session("lrp-summary", requestid.Data(r, lager.Data{"process-guid": guid}))
+
+	logger.Info("fetching-desired-lrp")
+	desiredLRP, err := handler.bbsClient.DesiredLRPByProcessGuid(logger, guid)
+	if err != nil {
+		logger.Error("fetching-desired-lrp-failed", err)
+		switch models.ConvertError(err).Type {
+		case models.Error_ResourceNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	logger.Info("fetching-actual-lrp-info")
+	actualLRPGroups, err := handler.bbsClient.ActualLRPGroupsByProcessGuid(logger, guid)
+	if err != nil {
+		logger.Error("fetching-actual-lrp-info-failed", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	instances := lrpstatus.LRPInstances(actualLRPGroups, nil, handler.clock)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	err = json.NewEncoder(w).Encode(Summarize(desiredLRP, instances))
+	if err != nil {
+		logger.Error("stream-response-failed", err)
+	}
+}
+
+func Summarize(desiredLRP *models.DesiredLRP, instances []cc_messages.LRPInstance) LRPSummary {
+	summary := LRPSummary{
+		ProcessGuid:      desiredLRP.ProcessGuid,
+		DesiredInstances: desiredLRP.Instances,
+		ActualInstances:  len(instances),
+		States:           make(map[cc_messages.LRPInstanceState]int),
+		MissingIndices:   []int32{},
+	}
+
+	presentIndices := make(map[uint]struct{}, len(instances))
+	for _, instance := range instances {
+		presentIndices[instance.Index] = struct{}{}
+		summary.States[instance.State]++
+
+		if summary.OldestSince == 0 || (instance.Since != 0 && instance.Since < summary.OldestSince) {
+			summary.OldestSince = instance.Since
+		}
+	}
+
+	for index := int32(0); index < desiredLRP.Instances; index++ {
+		if _, ok := presentIndices[uint(index)]; !ok {
+			summary.MissingIndices = append(summary.MissingIndices, index)
+		}
+	}
+
+	return summary
+}
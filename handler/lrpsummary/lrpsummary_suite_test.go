@@ -0,0 +1,13 @@
+package lrpsummary_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLrpsummary(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lrpsummary Suite")
+}
@@ -0,0 +1,137 @@
+package lrpsummary_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/lrpsummary"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LRPSummary", func() {
+	const guid = "my-guid"
+
+	var (
+		handler   http.Handler
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		bbsClient *fake_bbs.FakeClient
+		fakeClock *fakeclock.FakeClock
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		bbsClient = new(fake_bbs.FakeClient)
+		fakeClock = fakeclock.NewFakeClock(time.Date(2008, 8, 8, 8, 8, 8, 8, time.UTC))
+		handler = lrpsummary.NewHandler(bbsClient, fakeClock, lagertest.NewTestLogger("test"))
+		response = httptest.NewRecorder()
+		request, err = http.NewRequest("GET", "/v1/desired_lrps/:guid/summary", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		handler.ServeHTTP(response, request)
+	})
+
+	It("fails with no guid", func() {
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	Context("with a guid", func() {
+		var oldest, newest int64
+
+		BeforeEach(func() {
+			request.Form = url.Values{}
+			request.Form.Add(":guid", guid)
+
+			oldest = fakeClock.Now().UnixNano()
+			newest = fakeClock.Now().Add(time.Minute).UnixNano()
+
+			bbsClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{
+				ProcessGuid: guid,
+				Instances:   5,
+			}, nil)
+
+			bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{
+				{Instance: &models.ActualLRP{
+					ActualLRPKey: models.NewActualLRPKey(guid, 0, "some-domain"),
+					State:        models.ActualLRPStateRunning,
+					Since:        newest,
+				}},
+				{Instance: &models.ActualLRP{
+					ActualLRPKey: models.NewActualLRPKey(guid, 1, "some-domain"),
+					State:        models.ActualLRPStateRunning,
+					Since:        oldest,
+				}},
+				{Instance: &models.ActualLRP{
+					ActualLRPKey: models.NewActualLRPKey(guid, 3, "some-domain"),
+					State:        models.ActualLRPStateCrashed,
+					Since:        newest,
+				}},
+			}, nil)
+		})
+
+		It("summarizes desired and actual instances", func() {
+			var summary lrpsummary.LRPSummary
+
+			Expect(response.Code).To(Equal(http.StatusOK))
+			Expect(response.Header().Get("Content-Type")).To(Equal("application/json"))
+			err := json.Unmarshal(response.Body.Bytes(), &summary)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(summary).To(Equal(lrpsummary.LRPSummary{
+				ProcessGuid:      guid,
+				DesiredInstances: 5,
+				ActualInstances:  3,
+				States: map[cc_messages.LRPInstanceState]int{
+					cc_messages.LRPInstanceStateRunning: 2,
+					cc_messages.LRPInstanceStateCrashed: 1,
+				},
+				MissingIndices: []int32{2, 4},
+				OldestSince:    oldest / 1e9,
+			}))
+		})
+
+		Context("when the desiredLRP is not found", func() {
+			BeforeEach(func() {
+				bbsClient.DesiredLRPByProcessGuidReturns(nil, models.ErrResourceNotFound)
+			})
+
+			It("responds with a 404", func() {
+				Expect(response.Code).To(Equal(http.StatusNotFound))
+			})
+		})
+
+		Context("when fetching the desiredLRP fails", func() {
+			BeforeEach(func() {
+				bbsClient.DesiredLRPByProcessGuidReturns(nil, errors.New("boom"))
+			})
+
+			It("responds with a 500", func() {
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+
+		Context("when fetching actualLRPs fails", func() {
+			BeforeEach(func() {
+				bbsClient.ActualLRPGroupsByProcessGuidReturns(nil, errors.New("boom"))
+			})
+
+			It("responds with a 500", func() {
+				Expect(response.Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+	})
+})
@@ -0,0 +1,58 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/tps/handler/metricshistory"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+type FakeEnvelopeSource struct {
+	ContainerEnvelopesStub        func(appGuid string, authToken string) ([]*events.Envelope, error)
+	containerEnvelopesMutex       sync.RWMutex
+	containerEnvelopesArgsForCall []struct {
+		appGuid   string
+		authToken string
+	}
+	containerEnvelopesReturns struct {
+		result1 []*events.Envelope
+		result2 error
+	}
+}
+
+func (fake *FakeEnvelopeSource) ContainerEnvelopes(appGuid string, authToken string) ([]*events.Envelope, error) {
+	fake.containerEnvelopesMutex.Lock()
+	fake.containerEnvelopesArgsForCall = append(fake.containerEnvelopesArgsForCall, struct {
+		appGuid   string
+		authToken string
+	}{appGuid, authToken})
+	fake.containerEnvelopesMutex.Unlock()
+	if fake.ContainerEnvelopesStub != nil {
+		return fake.ContainerEnvelopesStub(appGuid, authToken)
+	} else {
+		return fake.containerEnvelopesReturns.result1, fake.containerEnvelopesReturns.result2
+	}
+}
+
+func (fake *FakeEnvelopeSource) ContainerEnvelopesCallCount() int {
+	fake.containerEnvelopesMutex.RLock()
+	defer fake.containerEnvelopesMutex.RUnlock()
+	return len(fake.containerEnvelopesArgsForCall)
+}
+
+func (fake *FakeEnvelopeSource) ContainerEnvelopesArgsForCall(i int) (string, string) {
+	fake.containerEnvelopesMutex.RLock()
+	defer fake.containerEnvelopesMutex.RUnlock()
+	return fake.containerEnvelopesArgsForCall[i].appGuid, fake.containerEnvelopesArgsForCall[i].authToken
+}
+
+func (fake *FakeEnvelopeSource) ContainerEnvelopesReturns(result1 []*events.Envelope, result2 error) {
+	fake.ContainerEnvelopesStub = nil
+	fake.containerEnvelopesReturns = struct {
+		result1 []*events.Envelope
+		result2 error
+	}{result1, result2}
+}
+
+var _ metricshistory.EnvelopeSource = new(FakeEnvelopeSource)
@@ -0,0 +1,248 @@
+package metricshistory
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+type Sample struct {
+	Time          time.Time `json:"time"`
+	CpuPercentage float64   `json:"cpu"`
+	MemoryBytes   uint64    `json:"mem"`
+	DiskBytes     uint64    `json:"disk"`
+}
+
+type Range struct {
+	Min float64 `json:"min"`
+	Avg float64 `json:"avg"`
+	Max float64 `json:"max"`
+}
+
+type Summary struct {
+	Samples       int       `json:"samples"`
+	From          time.Time `json:"from"`
+	To            time.Time `json:"to"`
+	CpuPercentage Range     `json:"cpu"`
+	MemoryBytes   Range     `json:"mem"`
+	DiskBytes     Range     `json:"disk"`
+}
+
+// Watched names an app whose stats history was requested recently.
+type Watched struct {
+	ProcessGuid string
+	LogGuid     string
+}
+
+// History keeps recent container metric samples of the apps whose stats
+// history was requested within the watch TTL, at most maxLogGuids of them;
+// beyond that the least recently requested app is dropped.
+type History struct {
+	clock              clock.Clock
+	samplesPerInstance int
+	maxLogGuids        int
+	watchTTL           time.Duration
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	// byRequest orders the entries from most to least recently requested.
+	byRequest *list.List
+}
+
+type entry struct {
+	Watched
+	instances     map[uint]*ring
+	lastRequested time.Time
+}
+
+type ring struct {
+	samples []Sample
+	next    int
+	full    bool
+	latest  time.Time
+}
+
+func New(clk clock.Clock, samplesPerInstance, maxLogGuids int, watchTTL time.Duration) *History {
+	return &History{
+		clock:              clk,
+		samplesPerInstance: samplesPerInstance,
+		maxLogGuids:        maxLogGuids,
+		watchTTL:           watchTTL,
+		entries:            make(map[string]*list.Element),
+		byRequest:          list.New(),
+	}
+}
+
+// Watch notes that the stats history of an app was requested, so that its
+// metrics are recorded until the watch TTL has passed without another request.
+func (h *History) Watch(processGuid, logGuid string) {
+	if h.samplesPerInstance <= 0 || h.maxLogGuids <= 0 {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	element, ok := h.entries[logGuid]
+	if !ok {
+		if h.byRequest.Len() >= h.maxLogGuids {
+			h.remove(h.byRequest.Back())
+		}
+		element = h.byRequest.PushFront(&entry{
+			Watched:   Watched{ProcessGuid: processGuid, LogGuid: logGuid},
+			instances: make(map[uint]*ring),
+		})
+		h.entries[logGuid] = element
+	} else {
+		h.byRequest.MoveToFront(element)
+	}
+
+	element.Value.(*entry).lastRequested = h.clock.Now()
+}
+
+// Watched drops the apps whose stats history was not requested within the
+// watch TTL and returns the others, most recently requested first.
+func (h *History) Watched() []Watched {
+	expired := h.clock.Now().Add(-h.watchTTL)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for back := h.byRequest.Back(); back != nil && back.Value.(*entry).lastRequested.Before(expired); back = h.byRequest.Back() {
+		h.remove(back)
+	}
+
+	watched := make([]Watched, 0, h.byRequest.Len())
+	for element := h.byRequest.Front(); element != nil; element = element.Next() {
+		watched = append(watched, element.Value.(*entry).Watched)
+	}
+
+	return watched
+}
+
+// Record adds a sample for an instance of logGuid, if it is watched. Samples
+// are stamped with the time the metric was emitted, so a sample that is not
+// newer than the latest one recorded for the instance is a repeat and is
+// dropped.
+func (h *History) Record(logGuid string, index uint, sample Sample) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	element, ok := h.entries[logGuid]
+	if !ok {
+		return
+	}
+	e := element.Value.(*entry)
+
+	r, ok := e.instances[index]
+	if !ok {
+		r = &ring{samples: make([]Sample, h.samplesPerInstance)}
+		e.instances[index] = r
+	}
+
+	if !sample.Time.After(r.latest) {
+		return
+	}
+
+	r.add(sample)
+}
+
+func (h *History) Samples(logGuid string, window time.Duration) map[uint][]Sample {
+	since := h.clock.Now().Add(-window)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	samples := make(map[uint][]Sample)
+
+	element, ok := h.entries[logGuid]
+	if !ok {
+		return samples
+	}
+
+	for index, r := range element.Value.(*entry).instances {
+		if instanceSamples := r.since(since); len(instanceSamples) > 0 {
+			samples[index] = instanceSamples
+		}
+	}
+
+	return samples
+}
+
+func Summarize(samples []Sample) *Summary {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	summary := &Summary{
+		Samples: len(samples),
+		From:    samples[0].Time,
+		To:      samples[len(samples)-1].Time,
+	}
+
+	cpu := make([]float64, len(samples))
+	mem := make([]float64, len(samples))
+	disk := make([]float64, len(samples))
+	for i, sample := range samples {
+		cpu[i] = sample.CpuPercentage
+		mem[i] = float64(sample.MemoryBytes)
+		disk[i] = float64(sample.DiskBytes)
+	}
+
+	summary.CpuPercentage = rangeOf(cpu)
+	summary.MemoryBytes = rangeOf(mem)
+	summary.DiskBytes = rangeOf(disk)
+
+	return summary
+}
+
+func rangeOf(values []float64) Range {
+	r := Range{Min: values[0], Max: values[0]}
+
+	total := 0.0
+	for _, value := range values {
+		if value < r.Min {
+			r.Min = value
+		}
+		if value > r.Max {
+			r.Max = value
+		}
+		total += value
+	}
+	r.Avg = total / float64(len(values))
+
+	return r
+}
+
+// remove must be called with h.lock held.
+func (h *History) remove(element *list.Element) {
+	h.byRequest.Remove(element)
+	delete(h.entries, element.Value.(*entry).LogGuid)
+}
+
+func (r *ring) add(sample Sample) {
+	r.latest = sample.Time
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *ring) since(t time.Time) []Sample {
+	ordered := r.samples[:r.next]
+	if r.full {
+		ordered = append(append([]Sample{}, r.samples[r.next:]...), r.samples[:r.next]...)
+	}
+
+	samples := []Sample{}
+	for _, sample := range ordered {
+		if !sample.Time.Before(t) {
+			samples = append(samples, sample)
+		}
+	}
+
+	return samples
+}
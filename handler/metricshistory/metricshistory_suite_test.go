@@ -0,0 +1,13 @@
+package metricshistory_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMetricshistory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Metricshistory Suite")
+}
@@ -0,0 +1,130 @@
+package metricshistory_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/tps/handler/metricshistory"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("History", func() {
+	var (
+		fakeClock *fakeclock.FakeClock
+		history   *metricshistory.History
+	)
+
+	sample := func(cpu float64, mem uint64) metricshistory.Sample {
+		return metricshistory.Sample{
+			Time:          fakeClock.Now(),
+			CpuPercentage: cpu,
+			MemoryBytes:   mem,
+			DiskBytes:     2048,
+		}
+	}
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		history = metricshistory.New(fakeClock, 3, 2, 10*time.Minute)
+		history.Watch("process-guid", "log-guid")
+	})
+
+	It("returns the samples recorded within the window per instance", func() {
+		history.Record("log-guid", 0, sample(0.1, 100))
+		history.Record("log-guid", 1, sample(0.2, 200))
+		fakeClock.Increment(time.Minute)
+		history.Record("log-guid", 0, sample(0.3, 300))
+		fakeClock.Increment(time.Minute)
+
+		samples := history.Samples("log-guid", 90*time.Second)
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0]).To(HaveLen(1))
+		Expect(samples[0][0].CpuPercentage).To(Equal(0.3))
+		Expect(samples[0][0].MemoryBytes).To(BeEquivalentTo(300))
+
+		samples = history.Samples("log-guid", 5*time.Minute)
+		Expect(samples).To(HaveLen(2))
+		Expect(samples[0]).To(HaveLen(2))
+		Expect(samples[0][0].MemoryBytes).To(BeEquivalentTo(100))
+		Expect(samples[0][1].MemoryBytes).To(BeEquivalentTo(300))
+		Expect(samples[1]).To(HaveLen(1))
+	})
+
+	It("keeps a bounded number of samples per instance in order", func() {
+		for i := uint64(1); i <= 5; i++ {
+			history.Record("log-guid", 0, sample(0, i))
+			fakeClock.Increment(time.Second)
+		}
+
+		samples := history.Samples("log-guid", time.Hour)[0]
+		Expect(samples).To(HaveLen(3))
+		Expect(samples[0].MemoryBytes).To(BeEquivalentTo(3))
+		Expect(samples[1].MemoryBytes).To(BeEquivalentTo(4))
+		Expect(samples[2].MemoryBytes).To(BeEquivalentTo(5))
+	})
+
+	It("drops samples that are not newer than the latest one for the instance", func() {
+		history.Record("log-guid", 0, sample(0.1, 100))
+		history.Record("log-guid", 0, sample(0.1, 100))
+
+		older := sample(0.5, 500)
+		older.Time = older.Time.Add(-time.Second)
+		history.Record("log-guid", 0, older)
+
+		Expect(history.Samples("log-guid", time.Hour)[0]).To(HaveLen(1))
+	})
+
+	It("does not record apps it does not watch", func() {
+		history.Record("other-log-guid", 0, sample(0, 1))
+
+		Expect(history.Samples("other-log-guid", time.Hour)).To(BeEmpty())
+	})
+
+	It("drops the least recently requested app when full", func() {
+		history.Watch("other-process-guid", "other-log-guid")
+		history.Watch("process-guid", "log-guid")
+		history.Watch("third-process-guid", "third-log-guid")
+
+		Expect(history.Watched()).To(Equal([]metricshistory.Watched{
+			{ProcessGuid: "third-process-guid", LogGuid: "third-log-guid"},
+			{ProcessGuid: "process-guid", LogGuid: "log-guid"},
+		}))
+	})
+
+	It("stops watching apps that were not requested within the watch TTL", func() {
+		history.Record("log-guid", 0, sample(0, 1))
+		fakeClock.Increment(5 * time.Minute)
+		history.Watch("other-process-guid", "other-log-guid")
+		fakeClock.Increment(6 * time.Minute)
+
+		Expect(history.Watched()).To(Equal([]metricshistory.Watched{
+			{ProcessGuid: "other-process-guid", LogGuid: "other-log-guid"},
+		}))
+		Expect(history.Samples("log-guid", time.Hour)).To(BeEmpty())
+	})
+
+	Describe("Summarize", func() {
+		It("computes min, avg and max", func() {
+			now := time.Now()
+			summary := metricshistory.Summarize([]metricshistory.Sample{
+				{Time: now, CpuPercentage: 0.1, MemoryBytes: 100, DiskBytes: 10},
+				{Time: now.Add(time.Second), CpuPercentage: 0.3, MemoryBytes: 300, DiskBytes: 30},
+			})
+
+			Expect(summary.Samples).To(Equal(2))
+			Expect(summary.From).To(Equal(now))
+			Expect(summary.To).To(Equal(now.Add(time.Second)))
+			Expect(summary.CpuPercentage.Min).To(Equal(0.1))
+			Expect(summary.CpuPercentage.Avg).To(BeNumerically("~", 0.2, 0.0001))
+			Expect(summary.CpuPercentage.Max).To(Equal(0.3))
+			Expect(summary.MemoryBytes).To(Equal(metricshistory.Range{Min: 100, Avg: 200, Max: 300}))
+			Expect(summary.DiskBytes).To(Equal(metricshistory.Range{Min: 10, Avg: 20, Max: 30}))
+		})
+
+		It("returns nil without samples", func() {
+			Expect(metricshistory.Summarize(nil)).To(BeNil())
+		})
+	})
+})
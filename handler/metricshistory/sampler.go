@@ -0,0 +1,151 @@
+package metricshistory
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/uaa_client"
+	"code.cloudfoundry.org/workpool"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+//go:generate counterfeiter -o fakes/fake_envelope_source.go . EnvelopeSource
+type EnvelopeSource interface {
+	ContainerEnvelopes(appGuid string, authToken string) ([]*events.Envelope, error)
+}
+
+// Sampler records the container metrics of the apps the history watches
+// once per interval, so that their window keeps filling between requests for
+// their stats. Crashed instances are recorded with zeroed usage, as the stats
+// endpoint reports them.
+type Sampler struct {
+	logger    lager.Logger
+	bbsClient bbs.Client
+	envelopes EnvelopeSource
+	tokens    uaa_client.TokenSource
+	history   *History
+	clock     clock.Clock
+	interval  time.Duration
+	workers   int
+}
+
+func NewSampler(
+	logger lager.Logger,
+	bbsClient bbs.Client,
+	envelopes EnvelopeSource,
+	tokens uaa_client.TokenSource,
+	history *History,
+	clk clock.Clock,
+	interval time.Duration,
+	workers int,
+) *Sampler {
+	return &Sampler{
+		logger:    logger.Session("metrics-history-sampler"),
+		bbsClient: bbsClient,
+		envelopes: envelopes,
+		tokens:    tokens,
+		history:   history,
+		clock:     clk,
+		interval:  interval,
+		workers:   workers,
+	}
+}
+
+func (s *Sampler) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := s.logger
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	close(ready)
+	logger.Info("started")
+
+	for {
+		select {
+		case <-ticker.C():
+			s.sample(logger.Session("sample"))
+
+		case <-signals:
+			logger.Info("stopping")
+			return nil
+		}
+	}
+}
+
+func (s *Sampler) sample(logger lager.Logger) {
+	watched := s.history.Watched()
+	if len(watched) == 0 {
+		return
+	}
+
+	token, err := s.tokens.Token()
+	if err != nil {
+		logger.Error("failed-fetching-token", err)
+		return
+	}
+
+	works := make([]func(), 0, len(watched))
+	for _, app := range watched {
+		app := app
+		works = append(works, func() {
+			s.sampleLRP(logger, app, token)
+		})
+	}
+
+	throttler, err := workpool.NewThrottler(s.workers, works)
+	if err != nil {
+		logger.Error("failed-constructing-throttler", err, lager.Data{"max-workers": s.workers, "num-works": len(works)})
+		return
+	}
+
+	throttler.Work()
+}
+
+func (s *Sampler) sampleLRP(logger lager.Logger, app Watched, token string) {
+	actualLRPGroups, err := s.bbsClient.ActualLRPGroupsByProcessGuid(logger, app.ProcessGuid)
+	if err != nil {
+		logger.Error("failed-fetching-actual-lrps", err, lager.Data{"process-guid": app.ProcessGuid})
+		return
+	}
+
+	crashedIndices := map[uint]bool{}
+	for _, group := range actualLRPGroups {
+		if group == nil || (group.Instance == nil && group.Evacuating == nil) {
+			continue
+		}
+
+		actual, _ := group.Resolve()
+		if actual.State == models.ActualLRPStateCrashed {
+			crashedIndices[uint(actual.Index)] = true
+		}
+	}
+
+	envelopes, err := s.envelopes.ContainerEnvelopes(app.LogGuid, token)
+	if err != nil {
+		logger.Error("failed-fetching-container-metrics", err, lager.Data{"log-guid": app.LogGuid})
+		return
+	}
+
+	for _, envelope := range envelopes {
+		metric := envelope.GetContainerMetric()
+		if metric == nil || envelope.Timestamp == nil {
+			continue
+		}
+
+		index := uint(metric.GetInstanceIndex())
+		sample := Sample{Time: time.Unix(0, envelope.GetTimestamp())}
+		if !crashedIndices[index] {
+			sample.CpuPercentage = metric.GetCpuPercentage() / 100
+			sample.MemoryBytes = metric.GetMemoryBytes()
+			sample.DiskBytes = metric.GetDiskBytes()
+		}
+
+		s.history.Record(app.LogGuid, index, sample)
+	}
+}
@@ -0,0 +1,149 @@
+package metricshistory_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/metricshistory"
+	"code.cloudfoundry.org/tps/handler/metricshistory/fakes"
+	uaafakes "code.cloudfoundry.org/tps/uaa_client/fakes"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sampler", func() {
+	var (
+		fakeClock *fakeclock.FakeClock
+		bbsClient *fake_bbs.FakeClient
+		envelopes *fakes.FakeEnvelopeSource
+		tokens    *uaafakes.FakeTokenSource
+		history   *metricshistory.History
+		process   ifrit.Process
+	)
+
+	envelope := func(index int32, timestamp time.Time, mem uint64) *events.Envelope {
+		return &events.Envelope{
+			Origin:    proto.String("rep"),
+			EventType: events.Envelope_ContainerMetric.Enum(),
+			Timestamp: proto.Int64(timestamp.UnixNano()),
+			ContainerMetric: &events.ContainerMetric{
+				ApplicationId: proto.String("log-guid"),
+				InstanceIndex: proto.Int32(index),
+				CpuPercentage: proto.Float64(50),
+				MemoryBytes:   proto.Uint64(mem),
+				DiskBytes:     proto.Uint64(2048),
+			},
+		}
+	}
+
+	recorded := func() map[uint][]metricshistory.Sample {
+		return history.Samples("log-guid", time.Hour)
+	}
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+		bbsClient = new(fake_bbs.FakeClient)
+		envelopes = new(fakes.FakeEnvelopeSource)
+		tokens = new(uaafakes.FakeTokenSource)
+		history = metricshistory.New(fakeClock, 10, 10, time.Hour)
+		history.Watch("process-guid", "log-guid")
+
+		tokens.TokenReturns("bearer token", nil)
+
+		crashed := models.NewUnclaimedActualLRP(models.NewActualLRPKey("process-guid", 1, cc_messages.AppLRPDomain), 1)
+		crashed.State = models.ActualLRPStateCrashed
+		bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{{Instance: crashed}}, nil)
+
+		emitted := fakeClock.Now().Add(-time.Second)
+		envelopes.ContainerEnvelopesReturns([]*events.Envelope{
+			envelope(0, emitted, 1024),
+			envelope(1, emitted, 4096),
+		}, nil)
+	})
+
+	JustBeforeEach(func() {
+		sampler := metricshistory.NewSampler(lagertest.NewTestLogger("test"), bbsClient, envelopes, tokens, history, fakeClock, time.Minute, 2)
+		process = ifrit.Invoke(sampler)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("records the metrics of every watched app each interval, stamped when they were emitted", func() {
+		fakeClock.WaitForWatcherAndIncrement(time.Minute)
+
+		Eventually(recorded).Should(HaveLen(2))
+		logGuid, token := envelopes.ContainerEnvelopesArgsForCall(0)
+		Expect(logGuid).To(Equal("log-guid"))
+		Expect(token).To(Equal("bearer token"))
+
+		_, processGuid := bbsClient.ActualLRPGroupsByProcessGuidArgsForCall(0)
+		Expect(processGuid).To(Equal("process-guid"))
+
+		samples := recorded()
+		Expect(samples[0]).To(HaveLen(1))
+		Expect(samples[0][0].Time).To(BeTemporally("==", time.Unix(999, 0)))
+		Expect(samples[0][0].CpuPercentage).To(Equal(0.5))
+		Expect(samples[0][0].MemoryBytes).To(BeEquivalentTo(1024))
+	})
+
+	It("zeroes the usage of crashed instances", func() {
+		fakeClock.WaitForWatcherAndIncrement(time.Minute)
+
+		Eventually(recorded).Should(HaveLen(2))
+		samples := recorded()
+		Expect(samples[1]).To(HaveLen(1))
+		Expect(samples[1][0].CpuPercentage).To(BeZero())
+		Expect(samples[1][0].MemoryBytes).To(BeZero())
+		Expect(samples[1][0].DiskBytes).To(BeZero())
+	})
+
+	It("does not record the same metric twice", func() {
+		fakeClock.WaitForWatcherAndIncrement(time.Minute)
+		Eventually(recorded).Should(HaveLen(2))
+
+		fakeClock.WaitForWatcherAndIncrement(time.Minute)
+		Eventually(envelopes.ContainerEnvelopesCallCount).Should(Equal(2))
+
+		Consistently(func() []metricshistory.Sample { return recorded()[0] }).Should(HaveLen(1))
+	})
+
+	Context("when no app is watched", func() {
+		BeforeEach(func() {
+			history = metricshistory.New(fakeClock, 10, 10, time.Hour)
+		})
+
+		It("neither fetches a token nor any metrics", func() {
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+
+			Consistently(tokens.TokenCallCount).Should(Equal(0))
+			Expect(envelopes.ContainerEnvelopesCallCount()).To(Equal(0))
+			Expect(bbsClient.ActualLRPGroupsByProcessGuidCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a token cannot be fetched", func() {
+		BeforeEach(func() {
+			tokens.TokenReturns("", errors.New("uaa down"))
+		})
+
+		It("skips the interval", func() {
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+
+			Eventually(tokens.TokenCallCount).Should(Equal(1))
+			Consistently(envelopes.ContainerEnvelopesCallCount).Should(Equal(0))
+		})
+	})
+})
@@ -2,19 +2,73 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/handler/requestid"
 )
 
 func LogWrap(handler http.Handler, logger lager.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		requestLog := logger.Session("request", lager.Data{
-			"method":  r.Method,
-			"request": r.URL.String(),
-		})
+		requestID := requestid.FromHeader(r)
+		if requestID != "" {
+			w.Header().Set(requestid.Header, requestID)
+			r = requestid.WithRequestID(r, requestID)
+		}
+
+		requestLog := logger.Session("request", requestid.Data(r, lager.Data{
+			"method":      r.Method,
+			"request":     r.URL.String(),
+			"remote-addr": r.RemoteAddr,
+		}))
 
 		requestLog.Debug("serving")
-		handler.ServeHTTP(w, r)
-		requestLog.Debug("done")
+
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w}
+		handler.ServeHTTP(recorder, r)
+
+		requestLog.Info("done", lager.Data{
+			"status":   recorder.Status(),
+			"duration": time.Since(start),
+			"bytes":    recorder.bytes,
+		})
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += n
+	return n, err
+}
+
+func (r *responseRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/tps/handler"
 	"code.cloudfoundry.org/tps/handler/handler_fakes"
+	"code.cloudfoundry.org/tps/handler/requestid"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
@@ -34,6 +35,10 @@ var _ = Describe("Middleware", func() {
 
 		Context("when the handler serves request", func() {
 			BeforeEach(func() {
+				wrappedHandler.ServeHTTPStub = func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusTeapot)
+					w.Write([]byte("hello"))
+				}
 				httpHandler.ServeHTTP(res, req)
 			})
 
@@ -48,6 +53,37 @@ var _ = Describe("Middleware", func() {
 			It("logs after serving", func() {
 				Expect(logger).To(gbytes.Say("done"))
 			})
+
+			It("logs the status, duration and size of the response at info level", func() {
+				Expect(logger).To(gbytes.Say(`"message":"test.request.done","log_level":1,"data":\{"bytes":5,"duration":\d+,.*"status":418`))
+			})
+
+			It("creates a request id and returns it", func() {
+				requestID := res.Header().Get(requestid.Header)
+				Expect(requestID).NotTo(BeEmpty())
+
+				_, servedRequest := wrappedHandler.ServeHTTPArgsForCall(0)
+				Expect(requestid.FromRequest(servedRequest)).To(Equal(requestID))
+				Expect(logger).To(gbytes.Say(`"request-id":"` + requestID + `"`))
+			})
+		})
+
+		Context("when the request carries a request id", func() {
+			BeforeEach(func() {
+				req.Header.Set(requestid.Header, "some-request-id")
+				httpHandler.ServeHTTP(res, req)
+			})
+
+			It("uses it", func() {
+				Expect(res.Header().Get(requestid.Header)).To(Equal("some-request-id"))
+
+				_, servedRequest := wrappedHandler.ServeHTTPArgsForCall(0)
+				Expect(requestid.FromRequest(servedRequest)).To(Equal("some-request-id"))
+			})
+
+			It("records the status as OK when the handler writes nothing", func() {
+				Expect(logger).To(gbytes.Say(`"status":200`))
+			})
 		})
 	})
 })
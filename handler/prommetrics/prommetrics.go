@@ -0,0 +1,132 @@
+package prommetrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "tps"
+	subsystem = "listener"
+)
+
+var (
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "requests_total",
+		Help:      "Requests served, by route name and status code.",
+	}, []string{"route", "code"})
+
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve requests, by route name.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+
+	StreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "stream_duration_seconds",
+		Help:      "Time streaming responses were held open, by route name.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"route"})
+
+	InFlightRequests = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "in_flight_requests",
+		Help:      "Requests currently holding a concurrency slot, by route name.",
+	}, []string{"route"})
+
+	RejectedRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "rejected_requests_total",
+		Help:      "Requests rejected because a concurrency limit was reached, by route name.",
+	}, []string{"route"})
+
+	BBSRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "bbs_request_duration_seconds",
+		Help:      "Time taken by BBS calls, by client method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	BBSRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "bbs_request_errors_total",
+		Help:      "Failed BBS calls, by client method.",
+	}, []string{"method"})
+
+	MetricsProviderRequestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "metrics_provider_request_duration_seconds",
+		Help:      "Time taken to fetch container metrics.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	MetricsProviderRequestErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "metrics_provider_request_errors_total",
+		Help:      "Failed container metrics fetches.",
+	})
+
+	BulkWorkQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "bulk_work_queue_depth",
+		Help:      "Per-guid lookups waiting for a bulk workpool worker, by handler.",
+	}, []string{"handler"})
+)
+
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		Requests,
+		RequestDuration,
+		StreamDuration,
+		InFlightRequests,
+		RejectedRequests,
+		BBSRequestDuration,
+		BBSRequestErrors,
+		MetricsProviderRequestDuration,
+		MetricsProviderRequestErrors,
+		BulkWorkQueueDepth,
+	)
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// QueuedWork counts works as queued until a worker picks each one up.
+func QueuedWork(handler string, works []func()) []func() {
+	queueDepth := BulkWorkQueueDepth.WithLabelValues(handler)
+	queueDepth.Add(float64(len(works)))
+
+	queued := make([]func(), len(works))
+	for i, work := range works {
+		work := work
+		queued[i] = func() {
+			queueDepth.Dec()
+			work()
+		}
+	}
+
+	return queued
+}
+
+// DiscardQueuedWork undoes QueuedWork for works that will never run.
+func DiscardQueuedWork(handler string, works []func()) {
+	BulkWorkQueueDepth.WithLabelValues(handler).Sub(float64(len(works)))
+}
@@ -0,0 +1,54 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"code.cloudfoundry.org/lager"
+	"github.com/nu7hatch/gouuid"
+)
+
+const Header = "X-Request-Id"
+
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,128}$`)
+
+type contextKey struct{}
+
+// FromHeader returns the request id supplied by the client, or a new one if
+// it is missing or not a reasonable identifier.
+func FromHeader(r *http.Request) string {
+	requestID := r.Header.Get(Header)
+	if validRequestID.MatchString(requestID) {
+		return requestID
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return ""
+	}
+
+	return id.String()
+}
+
+func WithRequestID(r *http.Request, requestID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, requestID))
+}
+
+func FromRequest(r *http.Request) string {
+	requestID, _ := r.Context().Value(contextKey{}).(string)
+	return requestID
+}
+
+// Data adds the request id, if any, to data for use in a lager session.
+func Data(r *http.Request, data lager.Data) lager.Data {
+	if data == nil {
+		data = lager.Data{}
+	}
+
+	if requestID := FromRequest(r); requestID != "" {
+		data["request-id"] = requestID
+	}
+
+	return data
+}
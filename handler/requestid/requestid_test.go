@@ -0,0 +1,58 @@
+package requestid_test
+
+import (
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/handler/requestid"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RequestID", func() {
+	var request *http.Request
+
+	BeforeEach(func() {
+		var err error
+		request, err = http.NewRequest("GET", "/v1/actual_lrps/some-guid", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("FromHeader", func() {
+		It("accepts the id supplied by the client", func() {
+			request.Header.Set(requestid.Header, "abc-123")
+			Expect(requestid.FromHeader(request)).To(Equal("abc-123"))
+		})
+
+		It("creates an id when none is supplied", func() {
+			first := requestid.FromHeader(request)
+			Expect(first).NotTo(BeEmpty())
+			Expect(requestid.FromHeader(request)).NotTo(Equal(first))
+		})
+
+		It("replaces ids that are not reasonable identifiers", func() {
+			request.Header.Set(requestid.Header, "bad id\n")
+			Expect(requestid.FromHeader(request)).NotTo(Equal("bad id\n"))
+
+			request.Header.Set(requestid.Header, strings.Repeat("a", 129))
+			Expect(requestid.FromHeader(request)).To(HaveLen(36))
+		})
+	})
+
+	Describe("Data", func() {
+		It("adds the request id to the lager data", func() {
+			request = requestid.WithRequestID(request, "abc-123")
+			Expect(requestid.FromRequest(request)).To(Equal("abc-123"))
+			Expect(requestid.Data(request, lager.Data{"process-guid": "some-guid"})).To(Equal(lager.Data{
+				"process-guid": "some-guid",
+				"request-id":   "abc-123",
+			}))
+		})
+
+		It("leaves the data alone when there is no request id", func() {
+			Expect(requestid.Data(request, nil)).To(Equal(lager.Data{}))
+		})
+	})
+})
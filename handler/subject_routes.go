@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"code.cloudfoundry.org/tps"
+)
+
+const AllRoutes = "*"
+
+// SubjectRoutes maps the common name of a client certificate to the names of
+// the tps.Routes it may call.
+type SubjectRoutes map[string][]string
+
+func LoadSubjectRoutes(path string) (SubjectRoutes, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var subjectRoutes SubjectRoutes
+	err = json.Unmarshal(data, &subjectRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = subjectRoutes.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return subjectRoutes, nil
+}
+
+func (subjectRoutes SubjectRoutes) Validate() error {
+	for subject, routes := range subjectRoutes {
+		for _, route := range routes {
+			if route == AllRoutes {
+				continue
+			}
+
+			if _, ok := tps.Routes.FindRouteByName(route); !ok {
+				return fmt.Errorf("unknown route %q for subject %q", route, subject)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (subjectRoutes SubjectRoutes) allows(subject, route string) bool {
+	for _, allowed := range subjectRoutes[subject] {
+		if allowed == route || allowed == AllRoutes {
+			return true
+		}
+	}
+
+	return false
+}
+
+type subjectRouteHandler struct {
+	route           string
+	subjectRoutes   SubjectRoutes
+	delegateHandler http.Handler
+}
+
+func (handler subjectRouteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if !handler.subjectRoutes.allows(r.TLS.PeerCertificates[0].Subject.CommonName, handler.route) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	handler.delegateHandler.ServeHTTP(w, r)
+}
@@ -0,0 +1,114 @@
+package handler_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/tps"
+	"code.cloudfoundry.org/tps/handler"
+	"code.cloudfoundry.org/tps/handler/lrpstats/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SubjectRoutes", func() {
+	Describe("routing by client certificate subject", func() {
+		var (
+			httpHandler http.Handler
+			request     *http.Request
+			response    *httptest.ResponseRecorder
+		)
+
+		BeforeEach(func() {
+			bbsClient := new(fake_bbs.FakeClient)
+			bbsClient.ActualLRPGroupsByProcessGuidReturns([]*models.ActualLRPGroup{}, nil)
+
+			subjectRoutes := handler.SubjectRoutes{
+				"cloud-controller": {handler.AllRoutes},
+				"dashboard":        {tps.BulkLRPStatus},
+			}
+
+			var err error
+			httpHandler, err = handler.New(bbsClient, &fakes.FakeMetricsProvider{}, nil, nil, subjectRoutes, handler.ConcurrencyLimits{MaxInFlight: 2}, 15, 0, lagertest.NewTestLogger("test"))
+			Expect(err).NotTo(HaveOccurred())
+
+			request, err = http.NewRequest("GET", "/v1/actual_lrps/some-guid", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			response = httptest.NewRecorder()
+		})
+
+		withSubject := func(commonName string) {
+			request.TLS = &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: commonName}}},
+			}
+		}
+
+		It("allows a subject mapped to all routes", func() {
+			withSubject("cloud-controller")
+			httpHandler.ServeHTTP(response, request)
+			Expect(response.Code).To(Equal(http.StatusOK))
+		})
+
+		It("forbids a subject that is not mapped to the route", func() {
+			withSubject("dashboard")
+			httpHandler.ServeHTTP(response, request)
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("forbids an unknown subject", func() {
+			withSubject("someone-else")
+			httpHandler.ServeHTTP(response, request)
+			Expect(response.Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("rejects requests without a client certificate", func() {
+			httpHandler.ServeHTTP(response, request)
+			Expect(response.Code).To(Equal(http.StatusUnauthorized))
+		})
+	})
+
+	Describe("LoadSubjectRoutes", func() {
+		var path string
+
+		writeFile := func(contents string) {
+			file, err := ioutil.TempFile("", "subject-routes")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = file.WriteString(contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			path = file.Name()
+		}
+
+		AfterEach(func() {
+			os.Remove(path)
+		})
+
+		It("loads the mapping", func() {
+			writeFile(`{"cloud-controller": ["*"], "dashboard": ["BulkLRPStatus", "BulkLRPStats"]}`)
+
+			subjectRoutes, err := handler.LoadSubjectRoutes(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subjectRoutes).To(Equal(handler.SubjectRoutes{
+				"cloud-controller": {"*"},
+				"dashboard":        {tps.BulkLRPStatus, tps.BulkLRPStats},
+			}))
+		})
+
+		It("fails on unknown route names", func() {
+			writeFile(`{"dashboard": ["NotARoute"]}`)
+
+			_, err := handler.LoadSubjectRoutes(path)
+			Expect(err).To(MatchError(ContainSubstring("NotARoute")))
+		})
+	})
+})
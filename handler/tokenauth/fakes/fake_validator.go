@@ -0,0 +1,53 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/tps/handler/tokenauth"
+)
+
+type FakeValidator struct {
+	ValidateStub        func(authorization string) error
+	validateMutex       sync.RWMutex
+	validateArgsForCall []struct {
+		authorization string
+	}
+	validateReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeValidator) Validate(authorization string) error {
+	fake.validateMutex.Lock()
+	fake.validateArgsForCall = append(fake.validateArgsForCall, struct {
+		authorization string
+	}{authorization})
+	fake.validateMutex.Unlock()
+	if fake.ValidateStub != nil {
+		return fake.ValidateStub(authorization)
+	} else {
+		return fake.validateReturns.result1
+	}
+}
+
+func (fake *FakeValidator) ValidateCallCount() int {
+	fake.validateMutex.RLock()
+	defer fake.validateMutex.RUnlock()
+	return len(fake.validateArgsForCall)
+}
+
+func (fake *FakeValidator) ValidateArgsForCall(i int) string {
+	fake.validateMutex.RLock()
+	defer fake.validateMutex.RUnlock()
+	return fake.validateArgsForCall[i].authorization
+}
+
+func (fake *FakeValidator) ValidateReturns(result1 error) {
+	fake.ValidateStub = nil
+	fake.validateReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ tokenauth.Validator = new(FakeValidator)
@@ -0,0 +1,289 @@
+package tokenauth
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+var ErrUnknownKey = errors.New("token signed with an unknown key")
+
+type KeySet interface {
+	Key(keyID string) (*rsa.PublicKey, error)
+}
+
+type BadResponseError struct {
+	StatusCode int
+}
+
+func (b *BadResponseError) Error() string {
+	return fmt.Sprintf("Got bad response from key set endpoint with status code: %d", b.StatusCode)
+}
+
+// keyMap treats a key without an id (as loaded from a PEM file) as a
+// fallback for any key id.
+type keyMap map[string]*rsa.PublicKey
+
+func (keys keyMap) lookup(keyID string) (*rsa.PublicKey, bool) {
+	if key, ok := keys[keyID]; ok {
+		return key, true
+	}
+
+	key, ok := keys[""]
+	return key, ok
+}
+
+type staticKeySet struct {
+	keys keyMap
+}
+
+func LoadKeySetFile(path string) (KeySet, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := ParseKeys(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &staticKeySet{keys: keys}, nil
+}
+
+func (s *staticKeySet) Key(keyID string) (*rsa.PublicKey, error) {
+	key, ok := s.keys.lookup(keyID)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+// FetchRetryInterval is how long a JWKS key set waits before fetching again
+// after a failed fetch.
+const FetchRetryInterval = 5 * time.Second
+
+type jwksKeySet struct {
+	url                string
+	httpClient         *http.Client
+	clock              clock.Clock
+	minRefreshInterval time.Duration
+
+	lock        sync.Mutex
+	keys        keyMap
+	lastFetched time.Time
+	lastFailed  time.Time
+	fetchErr    error
+	fetching    chan struct{}
+}
+
+// NewJWKSKeySet fetches keys from url on first use and again whenever a token
+// names a key it does not know, at most once per minRefreshInterval. A failed
+// fetch is retried after FetchRetryInterval.
+func NewJWKSKeySet(url string, httpClient *http.Client, clk clock.Clock, minRefreshInterval time.Duration) KeySet {
+	return &jwksKeySet{
+		url:                url,
+		httpClient:         httpClient,
+		clock:              clk,
+		minRefreshInterval: minRefreshInterval,
+		keys:               keyMap{},
+	}
+}
+
+func (s *jwksKeySet) Key(keyID string) (*rsa.PublicKey, error) {
+	s.lock.Lock()
+	if key, ok := s.keys.lookup(keyID); ok {
+		s.lock.Unlock()
+		return key, nil
+	}
+
+	fetching := s.fetching
+	if fetching == nil {
+		if !s.refreshDue() {
+			defer s.lock.Unlock()
+			return nil, s.lookupErr()
+		}
+
+		fetching = make(chan struct{})
+		s.fetching = fetching
+		s.lock.Unlock()
+
+		s.refresh(fetching)
+	} else {
+		s.lock.Unlock()
+		<-fetching
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if key, ok := s.keys.lookup(keyID); ok {
+		return key, nil
+	}
+
+	return nil, s.lookupErr()
+}
+
+// refresh fetches the keys without holding the lock, so that tokens signed
+// with known keys are not held up by a slow key set endpoint.
+func (s *jwksKeySet) refresh(fetching chan struct{}) {
+	keys, err := s.fetch()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err != nil {
+		s.lastFailed = s.clock.Now()
+		s.fetchErr = err
+	} else {
+		s.keys = keys
+		s.lastFetched = s.clock.Now()
+		s.lastFailed = time.Time{}
+		s.fetchErr = nil
+	}
+
+	s.fetching = nil
+	close(fetching)
+}
+
+func (s *jwksKeySet) refreshDue() bool {
+	if !s.lastFailed.IsZero() {
+		return s.clock.Since(s.lastFailed) >= FetchRetryInterval
+	}
+
+	return s.lastFetched.IsZero() || s.clock.Since(s.lastFetched) >= s.minRefreshInterval
+}
+
+func (s *jwksKeySet) lookupErr() error {
+	if s.fetchErr != nil {
+		return s.fetchErr
+	}
+
+	return ErrUnknownKey
+}
+
+func (s *jwksKeySet) fetch() (keyMap, error) {
+	resp, err := s.httpClient.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &BadResponseError{StatusCode: resp.StatusCode}
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKeys(data)
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Value   string `json:"value"`
+}
+
+// ParseKeys accepts either PEM encoded RSA public keys or a JSON Web Key Set.
+func ParseKeys(data []byte) (map[string]*rsa.PublicKey, error) {
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "-----BEGIN") {
+		return parsePEMKeys(data)
+	}
+
+	var set jsonWebKeySet
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := keyMap{}
+	for _, jwk := range set.Keys {
+		if jwk.KeyType != "RSA" {
+			continue
+		}
+
+		var key *rsa.PublicKey
+		if jwk.N != "" && jwk.E != "" {
+			key, err = rsaKey(jwk.N, jwk.E)
+		} else {
+			var pemKeys keyMap
+			pemKeys, err = parsePEMKeys([]byte(jwk.Value))
+			key = pemKeys[""]
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %s", jwk.KeyID, err)
+		}
+
+		keys[jwk.KeyID] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA keys found")
+	}
+
+	return keys, nil
+}
+
+func parsePEMKeys(data []byte) (keyMap, error) {
+	var block *pem.Block
+	for {
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no PEM encoded public key found")
+		}
+
+		if block.Type == "PUBLIC KEY" {
+			break
+		}
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not an RSA key")
+	}
+
+	return keyMap{"": key}, nil
+}
+
+func rsaKey(n, e string) (*rsa.PublicKey, error) {
+	modulus, err := decodeBase64(n)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent, err := decodeBase64(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}, nil
+}
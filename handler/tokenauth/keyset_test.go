@@ -0,0 +1,163 @@
+package tokenauth_test
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KeySet", func() {
+	var jwks []byte
+
+	BeforeEach(func() {
+		var err error
+		jwks, err = json.Marshal(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "key-1",
+				"n":   base64.RawURLEncoding.EncodeToString(privateKey.PublicKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(privateKey.PublicKey.E)).Bytes()),
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("ParseKeys", func() {
+		It("parses a JSON Web Key Set", func() {
+			keys, err := tokenauth.ParseKeys(jwks)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(HaveKey("key-1"))
+			Expect(keys["key-1"].N).To(Equal(privateKey.PublicKey.N))
+			Expect(keys["key-1"].E).To(Equal(privateKey.PublicKey.E))
+		})
+
+		It("parses a PEM encoded public key", func() {
+			der, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+			Expect(err).NotTo(HaveOccurred())
+
+			keys, err := tokenauth.ParseKeys(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(HaveKey(""))
+		})
+
+		It("fails when there are no RSA keys", func() {
+			_, err := tokenauth.ParseKeys([]byte(`{"keys":[{"kty":"EC","kid":"key-1"}]}`))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("LoadKeySetFile", func() {
+		var path string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile("", "token-keys")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = file.Write(jwks)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			path = file.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(path)
+		})
+
+		It("looks keys up by id", func() {
+			keySet, err := tokenauth.LoadKeySetFile(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			key, err := keySet.Key("key-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(key.N).To(Equal(privateKey.PublicKey.N))
+
+			_, err = keySet.Key("key-2")
+			Expect(err).To(Equal(tokenauth.ErrUnknownKey))
+		})
+	})
+
+	Describe("NewJWKSKeySet", func() {
+		var (
+			server    *ghttp.Server
+			fakeClock *fakeclock.FakeClock
+			keySet    tokenauth.KeySet
+		)
+
+		BeforeEach(func() {
+			server = ghttp.NewServer()
+			fakeClock = fakeclock.NewFakeClock(time.Now())
+			keySet = tokenauth.NewJWKSKeySet(server.URL()+"/token_keys", http.DefaultClient, fakeClock, time.Minute)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		Context("when the endpoint serves the keys", func() {
+			BeforeEach(func() {
+				server.RouteToHandler("GET", "/token_keys", ghttp.RespondWith(http.StatusOK, jwks))
+			})
+
+			It("fetches the keys once and caches them", func() {
+				_, err := keySet.Key("key-1")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = keySet.Key("key-1")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+			})
+
+			It("refetches unknown keys at most once per refresh interval", func() {
+				_, err := keySet.Key("key-2")
+				Expect(err).To(Equal(tokenauth.ErrUnknownKey))
+				_, err = keySet.Key("key-2")
+				Expect(err).To(Equal(tokenauth.ErrUnknownKey))
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+
+				fakeClock.Increment(time.Minute)
+
+				_, err = keySet.Key("key-2")
+				Expect(err).To(Equal(tokenauth.ErrUnknownKey))
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when the endpoint fails", func() {
+			BeforeEach(func() {
+				server.RouteToHandler("GET", "/token_keys", ghttp.RespondWith(http.StatusInternalServerError, nil))
+			})
+
+			It("returns a bad response error", func() {
+				_, err := keySet.Key("key-1")
+				Expect(err).To(Equal(&tokenauth.BadResponseError{StatusCode: http.StatusInternalServerError}))
+			})
+
+			It("retries after a short backoff rather than the refresh interval", func() {
+				_, err := keySet.Key("key-1")
+				Expect(err).To(HaveOccurred())
+				_, err = keySet.Key("key-1")
+				Expect(err).To(Equal(&tokenauth.BadResponseError{StatusCode: http.StatusInternalServerError}))
+				Expect(server.ReceivedRequests()).To(HaveLen(1))
+
+				server.RouteToHandler("GET", "/token_keys", ghttp.RespondWith(http.StatusOK, jwks))
+				fakeClock.Increment(tokenauth.FetchRetryInterval)
+
+				_, err = keySet.Key("key-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(server.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+	})
+})
@@ -0,0 +1,198 @@
+package tokenauth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"code.cloudfoundry.org/clock"
+)
+
+var (
+	ErrMissingBearerToken   = errors.New("missing bearer token")
+	ErrMalformedToken       = errors.New("malformed token")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature     = errors.New("invalid token signature")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrTokenNotYetValid     = errors.New("token not yet valid")
+	ErrInvalidAudience      = errors.New("token not issued for this audience")
+	ErrInsufficientScope    = errors.New("token is missing a required scope")
+)
+
+// KeySetUnavailableError is returned when the signing keys could not be
+// fetched, as opposed to the token being signed with an unknown key.
+type KeySetUnavailableError struct {
+	Err error
+}
+
+func (k *KeySetUnavailableError) Error() string {
+	return "key set unavailable: " + k.Err.Error()
+}
+
+var signingHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+//go:generate counterfeiter -o fakes/fake_validator.go . Validator
+type Validator interface {
+	Validate(authorization string) error
+}
+
+type validator struct {
+	keys           KeySet
+	audience       string
+	requiredScopes []string
+	clock          clock.Clock
+}
+
+func NewValidator(keys KeySet, audience string, requiredScopes []string, clk clock.Clock) Validator {
+	return &validator{
+		keys:           keys,
+		audience:       audience,
+		requiredScopes: requiredScopes,
+		clock:          clk,
+	}
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type claims struct {
+	Expires   float64    `json:"exp"`
+	NotBefore float64    `json:"nbf"`
+	Audience  stringList `json:"aud"`
+	Scope     stringList `json:"scope"`
+}
+
+// stringList accepts both a JSON array of strings and a single
+// space-separated string, as used for "aud" and "scope" claims.
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*l = strings.Fields(s)
+	return nil
+}
+
+func (l stringList) contains(value string) bool {
+	for _, v := range l {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *validator) Validate(authorization string) error {
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") || parts[1] == "" {
+		return ErrMissingBearerToken
+	}
+
+	segments := strings.Split(strings.TrimSpace(parts[1]), ".")
+	if len(segments) != 3 {
+		return ErrMalformedToken
+	}
+
+	var h header
+	if err := decodeSegment(segments[0], &h); err != nil {
+		return ErrMalformedToken
+	}
+
+	hash, ok := signingHashes[h.Algorithm]
+	if !ok {
+		return ErrUnsupportedAlgorithm
+	}
+
+	key, err := v.keys.Key(h.KeyID)
+	if err == ErrUnknownKey {
+		return err
+	} else if err != nil {
+		return &KeySetUnavailableError{Err: err}
+	}
+
+	signature, err := decodeBase64(segments[2])
+	if err != nil {
+		return ErrMalformedToken
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(segments[0] + "." + segments[1]))
+	if err := rsa.VerifyPKCS1v15(key, hash, hasher.Sum(nil), signature); err != nil {
+		return ErrInvalidSignature
+	}
+
+	var c claims
+	if err := decodeSegment(segments[1], &c); err != nil {
+		return ErrMalformedToken
+	}
+
+	now := float64(v.clock.Now().Unix())
+	if c.Expires == 0 || now >= c.Expires {
+		return ErrTokenExpired
+	}
+
+	if c.NotBefore != 0 && now < c.NotBefore {
+		return ErrTokenNotYetValid
+	}
+
+	if v.audience != "" && !c.Audience.contains(v.audience) {
+		return ErrInvalidAudience
+	}
+
+	for _, scope := range v.requiredScopes {
+		if !c.Scope.contains(scope) {
+			return ErrInsufficientScope
+		}
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := decodeBase64(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func decodeBase64(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+}
+
+// StatusCode maps a validation error to the response status. Only problems
+// with the token itself are reported as 401 or 403.
+func StatusCode(err error) int {
+	if _, ok := err.(*KeySetUnavailableError); ok {
+		return http.StatusServiceUnavailable
+	}
+
+	if err == ErrInsufficientScope {
+		return http.StatusForbidden
+	}
+
+	return http.StatusUnauthorized
+}
@@ -0,0 +1,44 @@
+package tokenauth_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+var privateKey *rsa.PrivateKey
+
+func TestTokenAuth(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Token Auth Suite")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+})
+
+func signToken(key *rsa.PrivateKey, keyID string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	Expect(err).NotTo(HaveOccurred())
+
+	payload, err := json.Marshal(claims)
+	Expect(err).NotTo(HaveOccurred())
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	hasher := crypto.SHA256.New()
+	hasher.Write([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hasher.Sum(nil))
+	Expect(err).NotTo(HaveOccurred())
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
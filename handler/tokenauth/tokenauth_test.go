@@ -0,0 +1,173 @@
+package tokenauth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/tps/handler/tokenauth"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type staticKeys map[string]*rsa.PublicKey
+
+func (keys staticKeys) Key(keyID string) (*rsa.PublicKey, error) {
+	key, ok := keys[keyID]
+	if !ok {
+		return nil, tokenauth.ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+type failingKeys struct{}
+
+func (failingKeys) Key(keyID string) (*rsa.PublicKey, error) {
+	return nil, &tokenauth.BadResponseError{StatusCode: http.StatusBadGateway}
+}
+
+var _ = Describe("Validator", func() {
+	var (
+		fakeClock      *fakeclock.FakeClock
+		validator      tokenauth.Validator
+		requiredScopes []string
+		claims         map[string]interface{}
+		token          string
+		err            error
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+		requiredScopes = []string{"cloud_controller.read"}
+		claims = map[string]interface{}{
+			"exp":   2000,
+			"aud":   []string{"cloud_controller", "tps"},
+			"scope": []string{"openid", "cloud_controller.read"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		validator = tokenauth.NewValidator(staticKeys{"key-1": &privateKey.PublicKey}, "tps", requiredScopes, fakeClock)
+		token = signToken(privateKey, "key-1", claims)
+		err = validator.Validate("bearer " + token)
+	})
+
+	It("accepts a valid token", func() {
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("accepts the scheme in any case", func() {
+		Expect(validator.Validate("Bearer " + token)).To(Succeed())
+	})
+
+	It("rejects a missing bearer token", func() {
+		Expect(validator.Validate(token)).To(Equal(tokenauth.ErrMissingBearerToken))
+		Expect(validator.Validate("bearer ")).To(Equal(tokenauth.ErrMissingBearerToken))
+	})
+
+	It("rejects a malformed token", func() {
+		Expect(validator.Validate("bearer not-a-jwt")).To(Equal(tokenauth.ErrMalformedToken))
+	})
+
+	It("rejects a token with a tampered payload", func() {
+		segments := strings.Split(token, ".")
+		tampered := signToken(privateKey, "key-1", map[string]interface{}{"exp": 3000})
+		segments[1] = strings.Split(tampered, ".")[1]
+
+		Expect(validator.Validate("bearer " + strings.Join(segments, "."))).To(Equal(tokenauth.ErrInvalidSignature))
+	})
+
+	It("rejects a token signed by another key", func() {
+		otherKey, keyErr := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(keyErr).NotTo(HaveOccurred())
+
+		Expect(validator.Validate("bearer " + signToken(otherKey, "key-1", claims))).To(Equal(tokenauth.ErrInvalidSignature))
+	})
+
+	It("rejects a token signed with an unknown key id", func() {
+		Expect(validator.Validate("bearer " + signToken(privateKey, "key-2", claims))).To(Equal(tokenauth.ErrUnknownKey))
+	})
+
+	Context("when the token has expired", func() {
+		BeforeEach(func() {
+			fakeClock.Increment(1000 * time.Second)
+		})
+
+		It("rejects it", func() {
+			Expect(err).To(Equal(tokenauth.ErrTokenExpired))
+		})
+	})
+
+	Context("when the token has no expiry", func() {
+		BeforeEach(func() {
+			delete(claims, "exp")
+		})
+
+		It("rejects it", func() {
+			Expect(err).To(Equal(tokenauth.ErrTokenExpired))
+		})
+	})
+
+	Context("when the token is not yet valid", func() {
+		BeforeEach(func() {
+			claims["nbf"] = 1500
+		})
+
+		It("rejects it", func() {
+			Expect(err).To(Equal(tokenauth.ErrTokenNotYetValid))
+		})
+	})
+
+	Context("when the token is for another audience", func() {
+		BeforeEach(func() {
+			claims["aud"] = "someone-else"
+		})
+
+		It("rejects it", func() {
+			Expect(err).To(Equal(tokenauth.ErrInvalidAudience))
+		})
+	})
+
+	Context("when the token is missing a required scope", func() {
+		BeforeEach(func() {
+			requiredScopes = []string{"cloud_controller.read", "cloud_controller.admin"}
+		})
+
+		It("rejects it as forbidden", func() {
+			Expect(err).To(Equal(tokenauth.ErrInsufficientScope))
+			Expect(tokenauth.StatusCode(err)).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Context("when scopes are given as a space separated string", func() {
+		BeforeEach(func() {
+			claims["scope"] = "openid cloud_controller.read"
+		})
+
+		It("accepts the token", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("when the key set is unavailable", func() {
+		It("reports it as unavailable rather than unauthorized", func() {
+			unavailable := tokenauth.NewValidator(failingKeys{}, "tps", requiredScopes, fakeClock)
+
+			err := unavailable.Validate("bearer " + token)
+			Expect(err).To(BeAssignableToTypeOf(&tokenauth.KeySetUnavailableError{}))
+			Expect(tokenauth.StatusCode(err)).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	Describe("StatusCode", func() {
+		It("maps token problems other than missing scopes to unauthorized", func() {
+			Expect(tokenauth.StatusCode(tokenauth.ErrTokenExpired)).To(Equal(http.StatusUnauthorized))
+			Expect(tokenauth.StatusCode(tokenauth.ErrUnknownKey)).To(Equal(http.StatusUnauthorized))
+		})
+	})
+})
@@ -0,0 +1,172 @@
+package logcache_client
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/urljoiner"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	readPath       = "/api/v1/read/%s"
+	readLookback   = 2 * time.Minute
+	readLimit      = 1000
+	requestTimeout = 5 * time.Second
+)
+
+type BadResponseError struct {
+	StatusCode int
+}
+
+func (b *BadResponseError) Error() string {
+	return fmt.Sprintf("Log Cache read failed with %d", b.StatusCode)
+}
+
+// Client reads container metrics from Log Cache. It satisfies
+// lrpstats.MetricsProvider.
+type Client struct {
+	readURI    string
+	httpClient *http.Client
+}
+
+func NewLogCacheClient(baseURI string, skipCertVerify bool) *Client {
+	httpClient := &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: skipCertVerify,
+				MinVersion:         tls.VersionTLS10,
+			},
+		},
+	}
+
+	return &Client{
+		readURI:    urljoiner.Join(baseURI, readPath),
+		httpClient: httpClient,
+	}
+}
+
+type readResponse struct {
+	Envelopes struct {
+		Batch []envelope `json:"batch"`
+	} `json:"envelopes"`
+}
+
+type envelope struct {
+	Timestamp  string `json:"timestamp"`
+	SourceID   string `json:"source_id"`
+	InstanceID string `json:"instance_id"`
+	Gauge      *struct {
+		Metrics map[string]struct {
+			Unit  string  `json:"unit"`
+			Value float64 `json:"value"`
+		} `json:"metrics"`
+	} `json:"gauge"`
+}
+
+func (c *Client) ContainerMetrics(appGuid string, authToken string) ([]*events.ContainerMetric, error) {
+	envelopes, err := c.ContainerEnvelopes(appGuid, authToken)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]*events.ContainerMetric, 0, len(envelopes))
+	for _, envelope := range envelopes {
+		metrics = append(metrics, envelope.GetContainerMetric())
+	}
+
+	return metrics, nil
+}
+
+// ContainerEnvelopes returns the latest container metric per instance along
+// with the time it was emitted.
+func (c *Client) ContainerEnvelopes(appGuid string, authToken string) ([]*events.Envelope, error) {
+	query := url.Values{}
+	query.Set("envelope_types", "GAUGE")
+	query.Set("start_time", strconv.FormatInt(time.Now().Add(-readLookback).UnixNano(), 10))
+	query.Set("limit", strconv.Itoa(readLimit))
+	query.Set("descending", "true")
+
+	request, err := http.NewRequest("GET", fmt.Sprintf(c.readURI, url.PathEscape(appGuid))+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", authToken)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, &BadResponseError{response.StatusCode}
+	}
+
+	var read readResponse
+	err = json.NewDecoder(response.Body).Decode(&read)
+	if err != nil {
+		return nil, err
+	}
+
+	return latestContainerEnvelopes(appGuid, read.Envelopes.Batch), nil
+}
+
+func (c *Client) Close() error {
+	return nil
+}
+
+// latestContainerEnvelopes expects envelopes in descending time order and
+// keeps the first container metric gauge seen for each instance.
+func latestContainerEnvelopes(appGuid string, batch []envelope) []*events.Envelope {
+	envelopes := []*events.Envelope{}
+	seen := map[int32]bool{}
+
+	for _, e := range batch {
+		if e.Gauge == nil {
+			continue
+		}
+
+		cpu, ok := e.Gauge.Metrics["cpu"]
+		if !ok {
+			continue
+		}
+
+		index, err := strconv.ParseInt(e.InstanceID, 10, 32)
+		if err != nil || seen[int32(index)] {
+			continue
+		}
+		seen[int32(index)] = true
+
+		timestamp, _ := strconv.ParseInt(e.Timestamp, 10, 64)
+
+		envelopes = append(envelopes, &events.Envelope{
+			Origin:    proto.String(e.SourceID),
+			EventType: events.Envelope_ContainerMetric.Enum(),
+			Timestamp: proto.Int64(timestamp),
+			ContainerMetric: &events.ContainerMetric{
+				ApplicationId: proto.String(appGuid),
+				InstanceIndex: proto.Int32(int32(index)),
+				CpuPercentage: proto.Float64(cpu.Value),
+				MemoryBytes:   proto.Uint64(uint64(e.Gauge.Metrics["memory"].Value)),
+				DiskBytes:     proto.Uint64(uint64(e.Gauge.Metrics["disk"].Value)),
+			},
+		})
+	}
+
+	return envelopes
+}
@@ -0,0 +1,13 @@
+package logcache_client_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLogCacheClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "LogCacheClient Suite")
+}
@@ -0,0 +1,124 @@
+package logcache_client_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/tps/handler/lrpstats"
+	"code.cloudfoundry.org/tps/logcache_client"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ lrpstats.MetricsProvider = &logcache_client.Client{}
+
+var _ = Describe("Log Cache Client", func() {
+	var (
+		fakeLogCache *ghttp.Server
+		client       *logcache_client.Client
+	)
+
+	BeforeEach(func() {
+		fakeLogCache = ghttp.NewServer()
+		client = logcache_client.NewLogCacheClient(fakeLogCache.URL(), true)
+	})
+
+	AfterEach(func() {
+		if fakeLogCache.HTTPTestServer != nil {
+			fakeLogCache.Close()
+		}
+	})
+
+	Describe("ContainerMetrics", func() {
+		Context("when Log Cache responds with gauges", func() {
+			BeforeEach(func() {
+				fakeLogCache.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/api/v1/read/log-guid"),
+						ghttp.VerifyHeaderKV("Authorization", "bearer token"),
+						func(w http.ResponseWriter, req *http.Request) {
+							Expect(req.URL.Query().Get("envelope_types")).To(Equal("GAUGE"))
+							Expect(req.URL.Query().Get("descending")).To(Equal("true"))
+							Expect(req.URL.Query().Get("start_time")).NotTo(BeEmpty())
+						},
+						ghttp.RespondWith(200, `{
+							"envelopes": {
+								"batch": [
+									{"timestamp": "3", "source_id": "log-guid", "instance_id": "1", "gauge": {"metrics": {
+										"cpu": {"unit": "percentage", "value": 5.5},
+										"memory": {"unit": "bytes", "value": 1024},
+										"disk": {"unit": "bytes", "value": 2048}
+									}}},
+									{"timestamp": "2", "source_id": "log-guid", "instance_id": "0", "gauge": {"metrics": {
+										"absolute_entitlement": {"unit": "nanoseconds", "value": 1}
+									}}},
+									{"timestamp": "2", "source_id": "log-guid", "instance_id": "0", "gauge": {"metrics": {
+										"cpu": {"unit": "percentage", "value": 3},
+										"memory": {"unit": "bytes", "value": 512},
+										"disk": {"unit": "bytes", "value": 256}
+									}}},
+									{"timestamp": "1", "source_id": "log-guid", "instance_id": "1", "gauge": {"metrics": {
+										"cpu": {"unit": "percentage", "value": 99},
+										"memory": {"unit": "bytes", "value": 1},
+										"disk": {"unit": "bytes", "value": 1}
+									}}}
+								]
+							}
+						}`),
+					),
+				)
+			})
+
+			It("returns the latest container metric per instance", func() {
+				metrics, err := client.ContainerMetrics("log-guid", "bearer token")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(metrics).To(HaveLen(2))
+
+				Expect(metrics[0].GetApplicationId()).To(Equal("log-guid"))
+				Expect(metrics[0].GetInstanceIndex()).To(BeEquivalentTo(1))
+				Expect(metrics[0].GetCpuPercentage()).To(Equal(5.5))
+				Expect(metrics[0].GetMemoryBytes()).To(BeEquivalentTo(1024))
+				Expect(metrics[0].GetDiskBytes()).To(BeEquivalentTo(2048))
+
+				Expect(metrics[1].GetInstanceIndex()).To(BeEquivalentTo(0))
+				Expect(metrics[1].GetCpuPercentage()).To(BeEquivalentTo(3))
+				Expect(metrics[1].GetMemoryBytes()).To(BeEquivalentTo(512))
+				Expect(metrics[1].GetDiskBytes()).To(BeEquivalentTo(256))
+			})
+
+			It("returns when each container metric was emitted", func() {
+				envelopes, err := client.ContainerEnvelopes("log-guid", "bearer token")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(envelopes).To(HaveLen(2))
+
+				Expect(envelopes[0].GetTimestamp()).To(BeEquivalentTo(3))
+				Expect(envelopes[0].GetContainerMetric().GetInstanceIndex()).To(BeEquivalentTo(1))
+				Expect(envelopes[1].GetTimestamp()).To(BeEquivalentTo(2))
+				Expect(envelopes[1].GetContainerMetric().GetInstanceIndex()).To(BeEquivalentTo(0))
+			})
+		})
+
+		Context("when Log Cache responds with an error", func() {
+			BeforeEach(func() {
+				fakeLogCache.AppendHandlers(ghttp.RespondWith(http.StatusUnauthorized, ""))
+			})
+
+			It("returns a BadResponseError", func() {
+				_, err := client.ContainerMetrics("log-guid", "bearer token")
+				Expect(err).To(Equal(&logcache_client.BadResponseError{StatusCode: http.StatusUnauthorized}))
+			})
+		})
+
+		Context("when Log Cache is unreachable", func() {
+			BeforeEach(func() {
+				fakeLogCache.Close()
+				fakeLogCache.HTTPTestServer = nil
+			})
+
+			It("returns an error", func() {
+				_, err := client.ContainerMetrics("log-guid", "bearer token")
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
@@ -3,13 +3,29 @@ package tps
 import "github.com/tedsuo/rata"
 
 const (
-	LRPStatus     = "LRPStatus"
-	LRPStats      = "LRPStats"
-	BulkLRPStatus = "BulkLRPStatus"
+	LRPStatus           = "LRPStatus"
+	LRPStats            = "LRPStats"
+	LRPEvents           = "LRPEvents"
+	BulkLRPStatus       = "BulkLRPStatus"
+	PostBulkLRPStatus   = "PostBulkLRPStatus"
+	BulkLRPStatusV2     = "BulkLRPStatusV2"
+	PostBulkLRPStatusV2 = "PostBulkLRPStatusV2"
+	BulkLRPStats        = "BulkLRPStats"
+	LRPSummary          = "LRPSummary"
+	ListActualLRPs      = "ListActualLRPs"
+	CellLRPs            = "CellLRPs"
 )
 
 var Routes = rata.Routes{
 	{Path: "/v1/bulk_actual_lrp_status", Method: "GET", Name: BulkLRPStatus},
+	{Path: "/v1/bulk_actual_lrp_status", Method: "POST", Name: PostBulkLRPStatus},
+	{Path: "/v1/bulk_actual_lrp_stats", Method: "GET", Name: BulkLRPStats},
+	{Path: "/v2/bulk_actual_lrp_status", Method: "GET", Name: BulkLRPStatusV2},
+	{Path: "/v2/bulk_actual_lrp_status", Method: "POST", Name: PostBulkLRPStatusV2},
+	{Path: "/v1/actual_lrps", Method: "GET", Name: ListActualLRPs},
 	{Path: "/v1/actual_lrps/:guid", Method: "GET", Name: LRPStatus},
 	{Path: "/v1/actual_lrps/:guid/stats", Method: "GET", Name: LRPStats},
+	{Path: "/v1/actual_lrps/:guid/events", Method: "GET", Name: LRPEvents},
+	{Path: "/v1/desired_lrps/:guid/summary", Method: "GET", Name: LRPSummary},
+	{Path: "/v1/cells/:cell_id/actual_lrps", Method: "GET", Name: CellLRPs},
 }
@@ -0,0 +1,133 @@
+package uaa_client
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+const (
+	tokenRequestTimeout = 5 * time.Second
+
+	// tokens are refreshed this long before UAA says they expire
+	expiryMargin = 30 * time.Second
+)
+
+//go:generate counterfeiter -o fakes/fake_token_source.go . TokenSource
+type TokenSource interface {
+	// Token returns an Authorization header value, such as "bearer abc".
+	Token() (string, error)
+}
+
+type BadResponseError struct {
+	StatusCode int
+}
+
+func (b *BadResponseError) Error() string {
+	return fmt.Sprintf("Token request failed with %d", b.StatusCode)
+}
+
+type tokenSource struct {
+	tokenURL     string
+	clientName   string
+	clientSecret string
+	httpClient   *http.Client
+	clock        clock.Clock
+
+	lock      sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewTokenSource fetches tokens from the UAA token endpoint with the client
+// credentials grant and reuses each token until shortly before it expires.
+// The UAA certificate is verified with tlsConfig, or against the system roots
+// when it is nil.
+func NewTokenSource(tokenURL, clientName, clientSecret string, tlsConfig *tls.Config, clk clock.Clock) TokenSource {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS10}
+	}
+
+	httpClient := &http.Client{
+		Timeout: tokenRequestTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig:     tlsConfig,
+		},
+	}
+
+	return &tokenSource{
+		tokenURL:     tokenURL,
+		clientName:   clientName,
+		clientSecret: clientSecret,
+		httpClient:   httpClient,
+		clock:        clk,
+	}
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (t *tokenSource) Token() (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.token != "" && t.clock.Now().Before(t.expiresAt) {
+		return t.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	request, err := http.NewRequest("POST", t.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.SetBasicAuth(t.clientName, t.clientSecret)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := t.httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", &BadResponseError{response.StatusCode}
+	}
+
+	var token tokenResponse
+	err = json.NewDecoder(response.Body).Decode(&token)
+	if err != nil {
+		return "", err
+	}
+
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("token response has no access token")
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" {
+		tokenType = "bearer"
+	}
+
+	t.token = tokenType + " " + token.AccessToken
+	t.expiresAt = t.clock.Now().Add(time.Duration(token.ExpiresIn)*time.Second - expiryMargin)
+
+	return t.token, nil
+}
@@ -0,0 +1,106 @@
+package uaa_client_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/tps/uaa_client"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("TokenSource", func() {
+	var (
+		fakeUAA     *ghttp.Server
+		fakeClock   *fakeclock.FakeClock
+		tokenSource uaa_client.TokenSource
+	)
+
+	BeforeEach(func() {
+		fakeUAA = ghttp.NewServer()
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		tokenSource = uaa_client.NewTokenSource(fakeUAA.URL()+"/oauth/token", "tps", "secret", nil, fakeClock)
+	})
+
+	AfterEach(func() {
+		fakeUAA.Close()
+	})
+
+	Context("when UAA issues a token", func() {
+		BeforeEach(func() {
+			fakeUAA.RouteToHandler("POST", "/oauth/token", ghttp.CombineHandlers(
+				ghttp.VerifyBasicAuth("tps", "secret"),
+				ghttp.VerifyContentType("application/x-www-form-urlencoded"),
+				func(w http.ResponseWriter, req *http.Request) {
+					Expect(req.FormValue("grant_type")).To(Equal("client_credentials"))
+				},
+				ghttp.RespondWith(http.StatusOK, `{"access_token": "abc", "token_type": "bearer", "expires_in": 600}`),
+			))
+		})
+
+		It("returns it as an authorization header value", func() {
+			token, err := tokenSource.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("bearer abc"))
+		})
+
+		It("reuses the token until shortly before it expires", func() {
+			_, err := tokenSource.Token()
+			Expect(err).NotTo(HaveOccurred())
+
+			fakeClock.Increment(5 * time.Minute)
+			_, err = tokenSource.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeUAA.ReceivedRequests()).To(HaveLen(1))
+
+			fakeClock.Increment(5 * time.Minute)
+			_, err = tokenSource.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeUAA.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("when UAA rejects the client", func() {
+		BeforeEach(func() {
+			fakeUAA.RouteToHandler("POST", "/oauth/token", ghttp.RespondWith(http.StatusUnauthorized, nil))
+		})
+
+		It("returns a bad response error", func() {
+			_, err := tokenSource.Token()
+			Expect(err).To(Equal(&uaa_client.BadResponseError{StatusCode: http.StatusUnauthorized}))
+		})
+	})
+
+	Context("when UAA serves TLS", func() {
+		BeforeEach(func() {
+			fakeUAA.Close()
+			fakeUAA = ghttp.NewTLSServer()
+			fakeUAA.RouteToHandler("POST", "/oauth/token", ghttp.RespondWith(http.StatusOK, `{"access_token": "abc", "expires_in": 600}`))
+		})
+
+		It("verifies its certificate", func() {
+			tokenSource = uaa_client.NewTokenSource(fakeUAA.URL()+"/oauth/token", "tps", "secret", nil, fakeClock)
+
+			_, err := tokenSource.Token()
+			Expect(err).To(HaveOccurred())
+			Expect(fakeUAA.ReceivedRequests()).To(BeEmpty())
+		})
+
+		It("trusts the given certificate authorities", func() {
+			certificate, err := x509.ParseCertificate(fakeUAA.HTTPTestServer.TLS.Certificates[0].Certificate[0])
+			Expect(err).NotTo(HaveOccurred())
+			roots := x509.NewCertPool()
+			roots.AddCert(certificate)
+
+			tokenSource = uaa_client.NewTokenSource(fakeUAA.URL()+"/oauth/token", "tps", "secret", &tls.Config{RootCAs: roots}, fakeClock)
+
+			token, err := tokenSource.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).To(Equal("bearer abc"))
+		})
+	})
+})
@@ -0,0 +1,11 @@
+package consulkey
+
+import "net/url"
+
+// Path returns the Consul KV path of key under prefix. The key is escaped,
+// which leaves no literal ';' in it, and then terminated with one, so that no
+// path is a prefix of another and deleting the tree at one path never deletes
+// another.
+func Path(prefix, key string) string {
+	return prefix + url.QueryEscape(key) + ";"
+}
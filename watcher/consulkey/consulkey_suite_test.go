@@ -0,0 +1,13 @@
+package consulkey_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestConsulKey(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "ConsulKey Suite")
+}
@@ -0,0 +1,23 @@
+package consulkey_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/tps/watcher/consulkey"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path", func() {
+	It("places the key under the prefix", func() {
+		Expect(consulkey.Path("v1/prefix/", "some-key")).To(Equal("v1/prefix/some-key;"))
+	})
+
+	It("never makes one path a prefix of another", func() {
+		short := consulkey.Path("v1/prefix/", "index:guid:1")
+		long := consulkey.Path("v1/prefix/", "index:guid:1;0")
+
+		Expect(strings.HasPrefix(long, short)).To(BeFalse())
+	})
+})
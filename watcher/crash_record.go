@@ -0,0 +1,136 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
+)
+
+type crashKey struct {
+	processGuid string
+	index       int32
+}
+
+type reportedCrash struct {
+	crashCount int32
+	since      int64
+	recordedAt time.Time
+}
+
+// missedBy reports whether an instance that has not been placed again since
+// crashing crashed again since c was recorded. Since is then the time of the
+// crash.
+func (c reportedCrash) missedBy(actualLRP *models.ActualLRP) bool {
+	if actualLRP.CrashCount > c.crashCount {
+		return true
+	}
+
+	// BBS resets the crash count once an instance has been running for a
+	// while, so a lower count only means a missed crash if it is newer
+	return actualLRP.CrashCount < c.crashCount && actualLRP.Since > c.since
+}
+
+// crashRecord remembers the last crash reported for every app instance so
+// that crashes which happened while the watcher was not subscribed to BBS
+// events can be found and reported.
+type crashRecord struct {
+	clock        clock.Clock
+	lock         sync.Mutex
+	reported     map[crashKey]reportedCrash
+	reconciledAt time.Time
+}
+
+func newCrashRecord(clock clock.Clock, reconciledAt time.Time) *crashRecord {
+	return &crashRecord{
+		clock:        clock,
+		reported:     map[crashKey]reportedCrash{},
+		reconciledAt: reconciledAt,
+	}
+}
+
+func (r *crashRecord) record(key models.ActualLRPKey, crashCount int32, since int64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.reported[crashKey{key.ProcessGuid, key.Index}] = reportedCrash{
+		crashCount: crashCount,
+		since:      since,
+		recordedAt: r.clock.Now(),
+	}
+}
+
+// reconcile returns the actual LRPs whose latest crash has not been reported
+// and records them as reported.
+//
+// Only instances that have not been placed again since crashing still carry
+// the crash: Since is then the time of the crash and no new instance guid has
+// been assigned. A CRASHED instance is missed if it crashed again since it was
+// recorded or, with no record, if it crashed after the previous
+// reconciliation, since anything older was either reported or predates the
+// watcher. An UNCLAIMED instance awaiting its restart is missed only if it
+// crashed again since it was recorded, as it may be unclaimed for other
+// reasons. Any other instance only tells about the instance that replaced the
+// crashed one, so its crash count is remembered for next time instead.
+func (r *crashRecord) reconcile(actualLRPs []*models.ActualLRP, startedAt time.Time) []*models.ActualLRP {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := r.clock.Now()
+	missed := []*models.ActualLRP{}
+	seen := map[crashKey]struct{}{}
+
+	for _, actualLRP := range actualLRPs {
+		key := crashKey{actualLRP.ProcessGuid, actualLRP.Index}
+		seen[key] = struct{}{}
+
+		if actualLRP.CrashCount == 0 {
+			continue
+		}
+
+		current := reportedCrash{
+			crashCount: actualLRP.CrashCount,
+			since:      actualLRP.Since,
+			recordedAt: now,
+		}
+
+		reported, ok := r.reported[key]
+		switch actualLRP.State {
+		case models.ActualLRPStateCrashed:
+			if ok && !reported.missedBy(actualLRP) {
+				continue
+			}
+			if !ok && actualLRP.Since < r.reconciledAt.UnixNano() {
+				continue
+			}
+
+		case models.ActualLRPStateUnclaimed:
+			if !ok || !reported.missedBy(actualLRP) {
+				r.reported[key] = current
+				continue
+			}
+
+		default:
+			r.reported[key] = current
+			continue
+		}
+
+		missed = append(missed, actualLRP)
+		r.reported[key] = current
+	}
+
+	// forget instances that are gone, but not ones recorded after the actual
+	// LRPs were fetched
+	for key, reported := range r.reported {
+		if _, ok := seen[key]; !ok && reported.recordedAt.Before(startedAt) {
+			delete(r.reported, key)
+		}
+	}
+
+	if startedAt.After(r.reconciledAt) {
+		r.reconciledAt = startedAt
+	}
+
+	return missed
+}
@@ -0,0 +1,129 @@
+package dedupe
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/consuladapter"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/watcher/consulkey"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	ConsulKeyPrefix      = "v1/tps_watcher/delivered_crashes/"
+	DefaultPruneInterval = time.Minute
+)
+
+// consulStore keeps delivered keys in Consul KV so that a newly elected
+// watcher knows what its predecessor already reported. Each value is the
+// time the key was delivered; expired and excess keys are pruned at most
+// once per pruneInterval.
+type consulStore struct {
+	kv            consuladapter.KV
+	clock         clock.Clock
+	ttl           time.Duration
+	maxEntries    int
+	pruneInterval time.Duration
+
+	lock       sync.Mutex
+	lastPruned time.Time
+}
+
+func NewConsulStore(consulClient consuladapter.Client, clock clock.Clock, ttl time.Duration, maxEntries int, pruneInterval time.Duration) Store {
+	return &consulStore{
+		kv:            consulClient.KV(),
+		clock:         clock,
+		ttl:           ttl,
+		maxEntries:    maxEntries,
+		pruneInterval: pruneInterval,
+	}
+}
+
+func (s *consulStore) Delivered(logger lager.Logger, key string) (bool, error) {
+	pair, _, err := s.kv.Get(consulkey.Path(ConsulKeyPrefix, key), nil)
+	if err != nil {
+		return false, err
+	}
+
+	if pair == nil {
+		return false, nil
+	}
+
+	deliveredAt, ok := parseDeliveredAt(pair)
+	if !ok {
+		return false, nil
+	}
+
+	return s.clock.Since(deliveredAt) < s.ttl, nil
+}
+
+func (s *consulStore) MarkDelivered(logger lager.Logger, key string) error {
+	_, err := s.kv.Put(&api.KVPair{
+		Key:   consulkey.Path(ConsulKeyPrefix, key),
+		Value: []byte(strconv.FormatInt(s.clock.Now().UnixNano(), 10)),
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	s.pruneIfDue(logger)
+	return nil
+}
+
+func (s *consulStore) pruneIfDue(logger lager.Logger) {
+	s.lock.Lock()
+	if s.clock.Since(s.lastPruned) < s.pruneInterval {
+		s.lock.Unlock()
+		return
+	}
+	s.lastPruned = s.clock.Now()
+	s.lock.Unlock()
+
+	logger = logger.Session("prune-delivered-crash-reports")
+
+	pairs, _, err := s.kv.List(ConsulKeyPrefix, nil)
+	if err != nil {
+		logger.Error("failed-listing-keys", err)
+		return
+	}
+
+	sort.Sort(byDeliveredAt(pairs))
+
+	excess := len(pairs) - s.maxEntries
+	for i, pair := range pairs {
+		deliveredAt, ok := parseDeliveredAt(pair)
+		if i >= excess && ok && s.clock.Since(deliveredAt) < s.ttl {
+			break
+		}
+
+		// paths are never a prefix of one another, so this only deletes pair
+		_, err := s.kv.DeleteTree(pair.Key, nil)
+		if err != nil {
+			logger.Error("failed-deleting-key", err, lager.Data{"key": pair.Key})
+			return
+		}
+	}
+}
+
+func parseDeliveredAt(pair *api.KVPair) (time.Time, bool) {
+	nanos, err := strconv.ParseInt(string(pair.Value), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, nanos), true
+}
+
+type byDeliveredAt api.KVPairs
+
+func (p byDeliveredAt) Len() int      { return len(p) }
+func (p byDeliveredAt) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byDeliveredAt) Less(i, j int) bool {
+	a, _ := parseDeliveredAt(p[i])
+	b, _ := parseDeliveredAt(p[j])
+	return a.Before(b)
+}
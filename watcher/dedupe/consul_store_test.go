@@ -0,0 +1,110 @@
+package dedupe_test
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/consuladapter/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/tps/watcher/dedupe"
+	"github.com/hashicorp/consul/api"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsulStore", func() {
+	var (
+		logger       *lagertest.TestLogger
+		fakeClock    *fakeclock.FakeClock
+		consulClient *fakes.FakeClient
+		kv           *fakes.FakeKV
+		store        dedupe.Store
+	)
+
+	deliveredAt := func(t time.Time) []byte {
+		return []byte(strconv.FormatInt(t.UnixNano(), 10))
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+
+		kv = new(fakes.FakeKV)
+		consulClient = new(fakes.FakeClient)
+		consulClient.KVReturns(kv)
+
+		store = dedupe.NewConsulStore(consulClient, fakeClock, time.Hour, 2, time.Minute)
+	})
+
+	Describe("Delivered", func() {
+		It("reports keys delivered within the ttl", func() {
+			kv.GetReturns(&api.KVPair{Value: deliveredAt(fakeClock.Now().Add(-time.Minute))}, nil, nil)
+
+			Expect(store.Delivered(logger, "instance:guid:1")).To(BeTrue())
+
+			key, _ := kv.GetArgsForCall(0)
+			Expect(key).To(Equal(dedupe.ConsulKeyPrefix + "instance%3Aguid%3A1;"))
+		})
+
+		It("ignores expired keys", func() {
+			kv.GetReturns(&api.KVPair{Value: deliveredAt(fakeClock.Now().Add(-2 * time.Hour))}, nil, nil)
+
+			Expect(store.Delivered(logger, "instance:guid:1")).To(BeFalse())
+		})
+
+		It("ignores missing keys", func() {
+			kv.GetReturns(nil, nil, nil)
+
+			Expect(store.Delivered(logger, "instance:guid:1")).To(BeFalse())
+		})
+
+		It("returns errors from consul", func() {
+			kv.GetReturns(nil, nil, errors.New("boom"))
+
+			_, err := store.Delivered(logger, "instance:guid:1")
+			Expect(err).To(MatchError("boom"))
+		})
+	})
+
+	Describe("MarkDelivered", func() {
+		BeforeEach(func() {
+			kv.ListReturns(api.KVPairs{
+				{Key: "newest", Value: deliveredAt(fakeClock.Now())},
+				{Key: "expired", Value: deliveredAt(fakeClock.Now().Add(-2 * time.Hour))},
+				{Key: "oldest-fresh", Value: deliveredAt(fakeClock.Now().Add(-time.Minute))},
+				{Key: "newer", Value: deliveredAt(fakeClock.Now().Add(-time.Second))},
+			}, nil, nil)
+		})
+
+		It("stores the delivery time", func() {
+			Expect(store.MarkDelivered(logger, "instance:guid:1")).To(Succeed())
+
+			Expect(kv.PutCallCount()).To(Equal(1))
+			pair, _ := kv.PutArgsForCall(0)
+			Expect(pair.Key).To(Equal(dedupe.ConsulKeyPrefix + "instance%3Aguid%3A1;"))
+			Expect(pair.Value).To(Equal(deliveredAt(fakeClock.Now())))
+		})
+
+		It("prunes expired and excess keys, at most once per interval", func() {
+			Expect(store.MarkDelivered(logger, "instance:guid:1")).To(Succeed())
+
+			Expect(kv.DeleteTreeCallCount()).To(Equal(2))
+			deleted := []string{}
+			for i := 0; i < kv.DeleteTreeCallCount(); i++ {
+				key, _ := kv.DeleteTreeArgsForCall(i)
+				deleted = append(deleted, key)
+			}
+			Expect(deleted).To(ConsistOf("expired", "oldest-fresh"))
+
+			Expect(store.MarkDelivered(logger, "instance:guid:2")).To(Succeed())
+			Expect(kv.ListCallCount()).To(Equal(1))
+
+			fakeClock.Increment(time.Minute)
+			Expect(store.MarkDelivered(logger, "instance:guid:3")).To(Succeed())
+			Expect(kv.ListCallCount()).To(Equal(2))
+		})
+	})
+})
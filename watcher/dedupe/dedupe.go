@@ -0,0 +1,126 @@
+package dedupe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/runtimeschema/metric"
+	"code.cloudfoundry.org/tps/cc_client"
+)
+
+const (
+	DefaultTTL        = 2 * time.Hour
+	DefaultMaxEntries = 20000
+)
+
+const duplicateCrashReports = metric.Counter("TPSDuplicateCrashReportsSkipped")
+
+//go:generate counterfeiter -o fakes/fake_store.go . Store
+type Store interface {
+	Delivered(logger lager.Logger, key string) (bool, error)
+	MarkDelivered(logger lager.Logger, key string) error
+}
+
+// CcClient skips crash reports that have already been delivered to Cloud
+// Controller, so that a crash seen both as an event and by reconciliation,
+// or by two watchers across a lock handoff, is only recorded once. Reports of
+// the same crash are handled one at a time, so that a report cannot pass the
+// check while another is still being delivered.
+type CcClient struct {
+	cc_client.CcClient
+
+	store Store
+
+	lock     sync.Mutex
+	inFlight map[string]chan struct{}
+}
+
+func NewCcClient(ccClient cc_client.CcClient, store Store) *CcClient {
+	return &CcClient{
+		CcClient: ccClient,
+		store:    store,
+		inFlight: map[string]chan struct{}{},
+	}
+}
+
+func (c *CcClient) AppCrashed(guid string, appCrashed cc_messages.AppCrashedRequest, logger lager.Logger) error {
+	keys := Keys(guid, appCrashed)
+
+	release := c.acquire(keys[len(keys)-1])
+	defer release()
+
+	for _, key := range keys {
+		delivered, err := c.store.Delivered(logger, key)
+		if err != nil {
+			// reporting twice is better than not reporting at all
+			logger.Error("failed-checking-delivered-crash-reports", err, lager.Data{"key": key})
+			continue
+		}
+
+		if delivered {
+			logger.Info("skipping-duplicate-crash-report", lager.Data{"key": key})
+			duplicateCrashReports.Increment()
+			return nil
+		}
+	}
+
+	err := c.CcClient.AppCrashed(guid, appCrashed, logger)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		err := c.store.MarkDelivered(logger, key)
+		if err != nil {
+			logger.Error("failed-marking-crash-report-delivered", err, lager.Data{"key": key})
+		}
+	}
+
+	return nil
+}
+
+// acquire waits until no other report holds key and returns a func that
+// releases it.
+func (c *CcClient) acquire(key string) func() {
+	c.lock.Lock()
+	for {
+		held, ok := c.inFlight[key]
+		if !ok {
+			break
+		}
+
+		c.lock.Unlock()
+		<-held
+		c.lock.Lock()
+	}
+
+	done := make(chan struct{})
+	c.inFlight[key] = done
+	c.lock.Unlock()
+
+	return func() {
+		c.lock.Lock()
+		delete(c.inFlight, key)
+		c.lock.Unlock()
+		close(done)
+	}
+}
+
+// Keys identifies a crash by its instance guid and crash count. Crashes found
+// by reconciliation may have no instance guid, so every crash is also
+// identified by process guid, index, crash count and crash timestamp, which
+// is always the last key. The timestamp tells apart later crashes at the same
+// index once BBS has reset the crash count. A crash has been delivered if any
+// of its keys has.
+func Keys(guid string, appCrashed cc_messages.AppCrashedRequest) []string {
+	indexKey := fmt.Sprintf("index:%s:%d:%d:%d", guid, appCrashed.Index, appCrashed.CrashCount, appCrashed.CrashTimestamp)
+	if appCrashed.Instance == "" {
+		return []string{indexKey}
+	}
+
+	instanceKey := fmt.Sprintf("instance:%s:%d", appCrashed.Instance, appCrashed.CrashCount)
+	return []string{instanceKey, indexKey}
+}
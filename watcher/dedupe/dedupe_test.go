@@ -0,0 +1,186 @@
+package dedupe_test
+
+import (
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	ccfakes "code.cloudfoundry.org/tps/cc_client/fakes"
+	"code.cloudfoundry.org/tps/watcher/dedupe"
+	"code.cloudfoundry.org/tps/watcher/dedupe/fakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dedupe", func() {
+	var (
+		logger    *lagertest.TestLogger
+		fakeClock *fakeclock.FakeClock
+		ccClient  *ccfakes.FakeCcClient
+		store     dedupe.Store
+		client    *dedupe.CcClient
+
+		crashed cc_messages.AppCrashedRequest
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+		ccClient = new(ccfakes.FakeCcClient)
+		store = dedupe.NewMemoryStore(fakeClock, time.Hour, 10)
+
+		crashed = cc_messages.AppCrashedRequest{
+			Instance:       "instance-guid",
+			Index:          1,
+			Reason:         "CRASHED",
+			CrashCount:     2,
+			CrashTimestamp: 1000,
+		}
+	})
+
+	JustBeforeEach(func() {
+		client = dedupe.NewCcClient(ccClient, store)
+	})
+
+	Describe("AppCrashed", func() {
+		It("delivers a crash report once", func() {
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(1))
+		})
+
+		It("delivers the next crash of the same instance", func() {
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			crashed.CrashCount = 3
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(2))
+		})
+
+		It("skips a reconciled report without an instance guid for a crash already delivered", func() {
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			crashed.Instance = ""
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(1))
+		})
+
+		It("skips a live report for a crash already delivered by reconciliation", func() {
+			reconciled := crashed
+			reconciled.Instance = ""
+			Expect(client.AppCrashed("process-guid", reconciled, logger)).To(Succeed())
+
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(1))
+		})
+
+		It("delivers a later crash at the same index once the crash count has been reset", func() {
+			reconciled := crashed
+			reconciled.Instance = ""
+			Expect(client.AppCrashed("process-guid", reconciled, logger)).To(Succeed())
+
+			reconciled.CrashTimestamp = 2000
+			Expect(client.AppCrashed("process-guid", reconciled, logger)).To(Succeed())
+
+			crashed.Instance = "new-instance-guid"
+			crashed.CrashTimestamp = 3000
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(3))
+		})
+
+		It("delivers concurrent reports of the same crash once", func() {
+			delivering := make(chan struct{})
+			proceed := make(chan struct{})
+			ccClient.AppCrashedStub = func(string, cc_messages.AppCrashedRequest, lager.Logger) error {
+				close(delivering)
+				<-proceed
+				return nil
+			}
+
+			done := make(chan error)
+			go func() {
+				done <- client.AppCrashed("process-guid", crashed, logger)
+			}()
+			Eventually(delivering).Should(BeClosed())
+
+			reconciled := crashed
+			reconciled.Instance = ""
+			go func() {
+				done <- client.AppCrashed("process-guid", reconciled, logger)
+			}()
+
+			Consistently(done).ShouldNot(Receive())
+			close(proceed)
+			Eventually(done).Should(Receive(BeNil()))
+			Eventually(done).Should(Receive(BeNil()))
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(1))
+		})
+
+		It("delivers again once the record has expired", func() {
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			fakeClock.Increment(time.Hour)
+			Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(2))
+		})
+
+		Context("when delivery fails", func() {
+			BeforeEach(func() {
+				ccClient.AppCrashedReturns(errors.New("cc is down"))
+			})
+
+			It("returns the error and does not record the report", func() {
+				Expect(client.AppCrashed("process-guid", crashed, logger)).To(MatchError("cc is down"))
+
+				ccClient.AppCrashedReturns(nil)
+				Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+				Expect(ccClient.AppCrashedCallCount()).To(Equal(2))
+			})
+		})
+
+		Context("when the store cannot be read", func() {
+			var fakeStore *fakes.FakeStore
+
+			BeforeEach(func() {
+				fakeStore = new(fakes.FakeStore)
+				fakeStore.DeliveredReturns(true, errors.New("consul is down"))
+				store = fakeStore
+			})
+
+			It("delivers the report anyway", func() {
+				Expect(client.AppCrashed("process-guid", crashed, logger)).To(Succeed())
+
+				Expect(ccClient.AppCrashedCallCount()).To(Equal(1))
+				Expect(fakeStore.DeliveredCallCount()).To(Equal(2))
+				Expect(fakeStore.MarkDeliveredCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("MemoryStore", func() {
+		It("forgets the oldest keys beyond its capacity", func() {
+			store = dedupe.NewMemoryStore(fakeClock, time.Hour, 2)
+
+			for _, key := range []string{"a", "b", "c"} {
+				Expect(store.MarkDelivered(logger, key)).To(Succeed())
+				fakeClock.Increment(time.Second)
+			}
+
+			Expect(store.Delivered(logger, "a")).To(BeFalse())
+			Expect(store.Delivered(logger, "b")).To(BeTrue())
+			Expect(store.Delivered(logger, "c")).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,100 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/watcher/dedupe"
+)
+
+type FakeStore struct {
+	DeliveredStub        func(logger lager.Logger, key string) (bool, error)
+	deliveredMutex       sync.RWMutex
+	deliveredArgsForCall []struct {
+		logger lager.Logger
+		key    string
+	}
+	deliveredReturns struct {
+		result1 bool
+		result2 error
+	}
+	MarkDeliveredStub        func(logger lager.Logger, key string) error
+	markDeliveredMutex       sync.RWMutex
+	markDeliveredArgsForCall []struct {
+		logger lager.Logger
+		key    string
+	}
+	markDeliveredReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeStore) Delivered(logger lager.Logger, key string) (bool, error) {
+	fake.deliveredMutex.Lock()
+	fake.deliveredArgsForCall = append(fake.deliveredArgsForCall, struct {
+		logger lager.Logger
+		key    string
+	}{logger, key})
+	fake.deliveredMutex.Unlock()
+	if fake.DeliveredStub != nil {
+		return fake.DeliveredStub(logger, key)
+	} else {
+		return fake.deliveredReturns.result1, fake.deliveredReturns.result2
+	}
+}
+
+func (fake *FakeStore) DeliveredCallCount() int {
+	fake.deliveredMutex.RLock()
+	defer fake.deliveredMutex.RUnlock()
+	return len(fake.deliveredArgsForCall)
+}
+
+func (fake *FakeStore) DeliveredArgsForCall(i int) (lager.Logger, string) {
+	fake.deliveredMutex.RLock()
+	defer fake.deliveredMutex.RUnlock()
+	return fake.deliveredArgsForCall[i].logger, fake.deliveredArgsForCall[i].key
+}
+
+func (fake *FakeStore) DeliveredReturns(result1 bool, result2 error) {
+	fake.DeliveredStub = nil
+	fake.deliveredReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) MarkDelivered(logger lager.Logger, key string) error {
+	fake.markDeliveredMutex.Lock()
+	fake.markDeliveredArgsForCall = append(fake.markDeliveredArgsForCall, struct {
+		logger lager.Logger
+		key    string
+	}{logger, key})
+	fake.markDeliveredMutex.Unlock()
+	if fake.MarkDeliveredStub != nil {
+		return fake.MarkDeliveredStub(logger, key)
+	} else {
+		return fake.markDeliveredReturns.result1
+	}
+}
+
+func (fake *FakeStore) MarkDeliveredCallCount() int {
+	fake.markDeliveredMutex.RLock()
+	defer fake.markDeliveredMutex.RUnlock()
+	return len(fake.markDeliveredArgsForCall)
+}
+
+func (fake *FakeStore) MarkDeliveredArgsForCall(i int) (lager.Logger, string) {
+	fake.markDeliveredMutex.RLock()
+	defer fake.markDeliveredMutex.RUnlock()
+	return fake.markDeliveredArgsForCall[i].logger, fake.markDeliveredArgsForCall[i].key
+}
+
+func (fake *FakeStore) MarkDeliveredReturns(result1 error) {
+	fake.MarkDeliveredStub = nil
+	fake.markDeliveredReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ dedupe.Store = new(FakeStore)
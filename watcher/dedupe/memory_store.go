@@ -0,0 +1,74 @@
+package dedupe
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+type deliveredKey struct {
+	key         string
+	deliveredAt time.Time
+}
+
+// memoryStore remembers delivered keys for ttl, keeping at most maxEntries
+// and forgetting the oldest first.
+type memoryStore struct {
+	clock      clock.Clock
+	ttl        time.Duration
+	maxEntries int
+
+	lock  sync.Mutex
+	keys  map[string]*list.Element
+	byAge *list.List
+}
+
+func NewMemoryStore(clock clock.Clock, ttl time.Duration, maxEntries int) Store {
+	return &memoryStore{
+		clock:      clock,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		keys:       map[string]*list.Element{},
+		byAge:      list.New(),
+	}
+}
+
+func (s *memoryStore) Delivered(logger lager.Logger, key string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	element, ok := s.keys[key]
+	if !ok {
+		return false, nil
+	}
+
+	return s.clock.Since(element.Value.(deliveredKey).deliveredAt) < s.ttl, nil
+}
+
+func (s *memoryStore) MarkDelivered(logger lager.Logger, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if element, ok := s.keys[key]; ok {
+		s.byAge.Remove(element)
+	}
+	s.keys[key] = s.byAge.PushBack(deliveredKey{key: key, deliveredAt: s.clock.Now()})
+
+	s.prune()
+	return nil
+}
+
+func (s *memoryStore) prune() {
+	for element := s.byAge.Front(); element != nil; element = s.byAge.Front() {
+		delivered := element.Value.(deliveredKey)
+		if s.byAge.Len() <= s.maxEntries && s.clock.Since(delivered.deliveredAt) < s.ttl {
+			return
+		}
+
+		s.byAge.Remove(element)
+		delete(s.keys, delivered.key)
+	}
+}
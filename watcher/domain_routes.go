@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+)
+
+const DefaultCrashReason = "CRASHED"
+
+// DomainRoute selects the LRP domains whose events the watcher reports.
+// Domain is a literal domain or a path.Match pattern such as "platform-*".
+// Crashes in matching domains are reported with Reason, and notifications go
+// to the named Sinks, or to every sink when Sinks is empty. Only the cf-apps
+// route may leave Sinks empty.
+type DomainRoute struct {
+	Domain string   `json:"domain"`
+	Reason string   `json:"reason,omitempty"`
+	Sinks  []string `json:"sinks,omitempty"`
+}
+
+// DomainRoutes are matched in order and the first match wins.
+type DomainRoutes []DomainRoute
+
+var DefaultDomainRoutes = DomainRoutes{
+	{Domain: cc_messages.AppLRPDomain, Reason: DefaultCrashReason},
+}
+
+func LoadDomainRoutes(path string) (DomainRoutes, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var domainRoutes DomainRoutes
+	err = json.Unmarshal(data, &domainRoutes)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range domainRoutes {
+		if domainRoutes[i].Reason == "" {
+			domainRoutes[i].Reason = DefaultCrashReason
+		}
+	}
+
+	return domainRoutes, nil
+}
+
+// Validate checks that every pattern is well formed and only names known
+// sinks. Cloud Controller only knows the process guids of cf-apps, so any
+// other route must name its sinks and may not name the cc sink.
+func (domainRoutes DomainRoutes) Validate(sinkNames []string) error {
+	if len(domainRoutes) == 0 {
+		return fmt.Errorf("no domains to watch")
+	}
+
+	known := map[string]bool{}
+	for _, name := range sinkNames {
+		known[name] = true
+	}
+
+	for _, route := range domainRoutes {
+		if route.Domain == "" {
+			return fmt.Errorf("domain route with no domain")
+		}
+
+		if _, err := path.Match(route.Domain, ""); err != nil {
+			return fmt.Errorf("invalid domain pattern %q: %s", route.Domain, err)
+		}
+
+		for _, sink := range route.Sinks {
+			if !known[sink] {
+				return fmt.Errorf("unknown sink %q for domain %q", sink, route.Domain)
+			}
+		}
+
+		if route.Domain == cc_messages.AppLRPDomain {
+			continue
+		}
+
+		if len(route.Sinks) == 0 {
+			return fmt.Errorf("domain %q must name its sinks, since only %q may be reported to %q", route.Domain, cc_messages.AppLRPDomain, sinks.CCSinkName)
+		}
+
+		for _, sink := range route.Sinks {
+			if sink == sinks.CCSinkName {
+				return fmt.Errorf("domain %q may not be reported to %q, since only %q may be", route.Domain, sinks.CCSinkName, cc_messages.AppLRPDomain)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (domainRoutes DomainRoutes) Match(domain string) (DomainRoute, bool) {
+	for _, route := range domainRoutes {
+		if matched, _ := path.Match(route.Domain, domain); matched {
+			return route, true
+		}
+	}
+
+	return DomainRoute{}, false
+}
+
+// domainFilters returns the BBS domain filters that together list every
+// actual LRP in a watched domain. A single unfiltered query is used once any
+// route is a pattern.
+func (domainRoutes DomainRoutes) domainFilters() []string {
+	domains := []string{}
+	seen := map[string]bool{}
+
+	for _, route := range domainRoutes {
+		if strings.ContainsAny(route.Domain, `*?[\`) {
+			return []string{""}
+		}
+
+		if !seen[route.Domain] {
+			seen[route.Domain] = true
+			domains = append(domains, route.Domain)
+		}
+	}
+
+	return domains
+}
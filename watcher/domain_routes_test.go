@@ -0,0 +1,108 @@
+package watcher_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DomainRoutes", func() {
+	Describe("Match", func() {
+		domainRoutes := watcher.DomainRoutes{
+			{Domain: "platform-routing", Reason: "ROUTER_CRASHED"},
+			{Domain: "platform-*", Reason: "PLATFORM_CRASHED"},
+		}
+
+		It("returns the first matching route", func() {
+			route, ok := domainRoutes.Match("platform-routing")
+			Expect(ok).To(BeTrue())
+			Expect(route.Reason).To(Equal("ROUTER_CRASHED"))
+
+			route, ok = domainRoutes.Match("platform-logging")
+			Expect(ok).To(BeTrue())
+			Expect(route.Reason).To(Equal("PLATFORM_CRASHED"))
+		})
+
+		It("does not match other domains", func() {
+			_, ok := domainRoutes.Match(cc_messages.AppLRPDomain)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("watches the cc-app Domain by default", func() {
+			route, ok := watcher.DefaultDomainRoutes.Match(cc_messages.AppLRPDomain)
+			Expect(ok).To(BeTrue())
+			Expect(route.Reason).To(Equal("CRASHED"))
+			Expect(route.Sinks).To(BeEmpty())
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts known sinks", func() {
+			domainRoutes := watcher.DomainRoutes{{Domain: "platform-*", Sinks: []string{"sre"}}}
+			Expect(domainRoutes.Validate([]string{"cc", "sre"})).To(Succeed())
+		})
+
+		It("rejects unknown sinks", func() {
+			domainRoutes := watcher.DomainRoutes{{Domain: "platform-*", Sinks: []string{"pager"}}}
+			Expect(domainRoutes.Validate([]string{"cc"})).To(MatchError(ContainSubstring("pager")))
+		})
+
+		It("accepts the default routes", func() {
+			Expect(watcher.DefaultDomainRoutes.Validate([]string{"cc"})).To(Succeed())
+		})
+
+		It("rejects other domains that would be reported to Cloud Controller", func() {
+			domainRoutes := watcher.DomainRoutes{{Domain: "platform-*"}}
+			Expect(domainRoutes.Validate([]string{"cc", "sre"})).To(MatchError(ContainSubstring("must name its sinks")))
+
+			domainRoutes = watcher.DomainRoutes{{Domain: "platform-*", Sinks: []string{"sre", "cc"}}}
+			Expect(domainRoutes.Validate([]string{"cc", "sre"})).To(MatchError(ContainSubstring(`may not be reported to "cc"`)))
+
+			domainRoutes = watcher.DomainRoutes{{Domain: "cf-*", Sinks: []string{"cc"}}}
+			Expect(domainRoutes.Validate([]string{"cc"})).To(HaveOccurred())
+		})
+
+		It("rejects malformed patterns", func() {
+			domainRoutes := watcher.DomainRoutes{{Domain: "platform-[", Sinks: []string{"sre"}}}
+			Expect(domainRoutes.Validate([]string{"sre"})).To(MatchError(ContainSubstring("invalid domain pattern")))
+		})
+
+		It("rejects an empty list", func() {
+			Expect(watcher.DomainRoutes{}.Validate(nil)).To(HaveOccurred())
+		})
+	})
+
+	Describe("LoadDomainRoutes", func() {
+		var path string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile("", "domain-routes")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = file.WriteString(`[
+				{"domain": "cf-apps"},
+				{"domain": "platform-*", "reason": "PLATFORM_CRASHED", "sinks": ["sre"]}
+			]`)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			path = file.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(path)
+		})
+
+		It("loads the routes, defaulting the reason", func() {
+			domainRoutes, err := watcher.LoadDomainRoutes(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(domainRoutes).To(Equal(watcher.DomainRoutes{
+				{Domain: "cf-apps", Reason: "CRASHED"},
+				{Domain: "platform-*", Reason: "PLATFORM_CRASHED", Sinks: []string{"sre"}},
+			}))
+		})
+	})
+})
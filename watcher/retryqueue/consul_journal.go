@@ -0,0 +1,63 @@
+package retryqueue
+
+import (
+	"encoding/json"
+
+	"code.cloudfoundry.org/consuladapter"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/watcher/consulkey"
+	"github.com/hashicorp/consul/api"
+)
+
+const ConsulKeyPrefix = "v1/tps_watcher/crash_retries/"
+
+// consulJournal keeps one key per pending entry in Consul KV, so that the
+// next elected watcher picks up the reports its predecessor did not deliver.
+type consulJournal struct {
+	logger lager.Logger
+	kv     consuladapter.KV
+}
+
+func NewConsulJournal(logger lager.Logger, consulClient consuladapter.Client) Journal {
+	return &consulJournal{
+		logger: logger.Session("consul-journal"),
+		kv:     consulClient.KV(),
+	}
+}
+
+func (j *consulJournal) Load() ([]Entry, error) {
+	pairs, _, err := j.kv.List(ConsulKeyPrefix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, pair := range pairs {
+		var entry Entry
+		err := json.Unmarshal(pair.Value, &entry)
+		if err != nil {
+			j.logger.Error("discarding-unreadable-entry", err, lager.Data{"key": pair.Key})
+			j.kv.DeleteTree(pair.Key, nil)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (j *consulJournal) Save(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = j.kv.Put(&api.KVPair{Key: consulkey.Path(ConsulKeyPrefix, entry.ID), Value: data}, nil)
+	return err
+}
+
+func (j *consulJournal) Remove(id string) error {
+	_, err := j.kv.DeleteTree(consulkey.Path(ConsulKeyPrefix, id), nil)
+	return err
+}
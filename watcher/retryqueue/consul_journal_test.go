@@ -0,0 +1,97 @@
+package retryqueue_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/consuladapter/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/retryqueue"
+	"github.com/hashicorp/consul/api"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ConsulJournal", func() {
+	var (
+		logger       *lagertest.TestLogger
+		consulClient *fakes.FakeClient
+		kv           *fakes.FakeKV
+		journal      retryqueue.Journal
+		entry        retryqueue.Entry
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+
+		kv = new(fakes.FakeKV)
+		consulClient = new(fakes.FakeClient)
+		consulClient.KVReturns(kv)
+
+		journal = retryqueue.NewConsulJournal(logger, consulClient)
+
+		entry = retryqueue.Entry{
+			ID:          "entry-id",
+			ProcessGuid: "process-guid",
+			Request:     cc_messages.AppCrashedRequest{Instance: "instance-guid", Index: 1},
+			EnqueuedAt:  time.Unix(990, 0).UTC(),
+			Attempts:    2,
+			NextAttempt: time.Unix(995, 0).UTC(),
+		}
+	})
+
+	Describe("Save", func() {
+		It("stores the entry under its id", func() {
+			Expect(journal.Save(entry)).To(Succeed())
+
+			Expect(kv.PutCallCount()).To(Equal(1))
+			pair, _ := kv.PutArgsForCall(0)
+			Expect(pair.Key).To(Equal(retryqueue.ConsulKeyPrefix + "entry-id;"))
+
+			var saved retryqueue.Entry
+			Expect(json.Unmarshal(pair.Value, &saved)).To(Succeed())
+			Expect(saved).To(Equal(entry))
+		})
+	})
+
+	Describe("Remove", func() {
+		It("deletes only the entry's key", func() {
+			Expect(journal.Remove("entry-id")).To(Succeed())
+
+			Expect(kv.DeleteTreeCallCount()).To(Equal(1))
+			key, _ := kv.DeleteTreeArgsForCall(0)
+			Expect(key).To(Equal(retryqueue.ConsulKeyPrefix + "entry-id;"))
+		})
+	})
+
+	Describe("Load", func() {
+		It("returns the stored entries, discarding unreadable ones", func() {
+			data, err := json.Marshal(entry)
+			Expect(err).NotTo(HaveOccurred())
+
+			kv.ListReturns(api.KVPairs{
+				{Key: retryqueue.ConsulKeyPrefix + "entry-id;", Value: data},
+				{Key: retryqueue.ConsulKeyPrefix + "garbage;", Value: []byte("{")},
+			}, nil, nil)
+
+			Expect(journal.Load()).To(Equal([]retryqueue.Entry{entry}))
+
+			prefix, _ := kv.ListArgsForCall(0)
+			Expect(prefix).To(Equal(retryqueue.ConsulKeyPrefix))
+
+			Expect(kv.DeleteTreeCallCount()).To(Equal(1))
+			key, _ := kv.DeleteTreeArgsForCall(0)
+			Expect(key).To(Equal(retryqueue.ConsulKeyPrefix + "garbage;"))
+		})
+
+		It("returns errors from consul", func() {
+			kv.ListReturns(nil, nil, errors.New("boom"))
+
+			_, err := journal.Load()
+			Expect(err).To(MatchError("boom"))
+		})
+	})
+})
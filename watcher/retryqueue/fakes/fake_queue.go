@@ -0,0 +1,47 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/retryqueue"
+)
+
+type FakeQueue struct {
+	EnqueueStub        func(logger lager.Logger, processGuid string, request cc_messages.AppCrashedRequest)
+	enqueueMutex       sync.RWMutex
+	enqueueArgsForCall []struct {
+		logger      lager.Logger
+		processGuid string
+		request     cc_messages.AppCrashedRequest
+	}
+}
+
+func (fake *FakeQueue) Enqueue(logger lager.Logger, processGuid string, request cc_messages.AppCrashedRequest) {
+	fake.enqueueMutex.Lock()
+	fake.enqueueArgsForCall = append(fake.enqueueArgsForCall, struct {
+		logger      lager.Logger
+		processGuid string
+		request     cc_messages.AppCrashedRequest
+	}{logger, processGuid, request})
+	fake.enqueueMutex.Unlock()
+	if fake.EnqueueStub != nil {
+		fake.EnqueueStub(logger, processGuid, request)
+	}
+}
+
+func (fake *FakeQueue) EnqueueCallCount() int {
+	fake.enqueueMutex.RLock()
+	defer fake.enqueueMutex.RUnlock()
+	return len(fake.enqueueArgsForCall)
+}
+
+func (fake *FakeQueue) EnqueueArgsForCall(i int) (lager.Logger, string, cc_messages.AppCrashedRequest) {
+	fake.enqueueMutex.RLock()
+	defer fake.enqueueMutex.RUnlock()
+	return fake.enqueueArgsForCall[i].logger, fake.enqueueArgsForCall[i].processGuid, fake.enqueueArgsForCall[i].request
+}
+
+var _ retryqueue.Queue = new(FakeQueue)
@@ -0,0 +1,169 @@
+package retryqueue
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+)
+
+const (
+	journalExtension = ".json"
+	tempFilePrefix   = "entry"
+)
+
+type Entry struct {
+	ID          string                        `json:"id"`
+	ProcessGuid string                        `json:"process_guid"`
+	Request     cc_messages.AppCrashedRequest `json:"request"`
+	EnqueuedAt  time.Time                     `json:"enqueued_at"`
+	Attempts    int                           `json:"attempts"`
+	NextAttempt time.Time                     `json:"next_attempt"`
+}
+
+type Journal interface {
+	Load() ([]Entry, error)
+	Save(entry Entry) error
+	Remove(id string) error
+}
+
+// fileJournal keeps one file per pending entry so that saving or removing a
+// report never rewrites the rest of the journal.
+type fileJournal struct {
+	logger lager.Logger
+	dir    string
+}
+
+func NewFileJournal(logger lager.Logger, dir string) (Journal, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileJournal{
+		logger: logger.Session("file-journal", lager.Data{"dir": dir}),
+		dir:    dir,
+	}, nil
+}
+
+func (j *fileJournal) Load() ([]Entry, error) {
+	files, err := ioutil.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		if !strings.HasSuffix(file.Name(), journalExtension) {
+			// left behind by a save that never got to rename it
+			if strings.HasPrefix(file.Name(), tempFilePrefix) {
+				j.logger.Info("removing-stale-temp-file", lager.Data{"file": file.Name()})
+				os.Remove(filepath.Join(j.dir, file.Name()))
+			}
+			continue
+		}
+
+		path := filepath.Join(j.dir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var entry Entry
+		err = json.Unmarshal(data, &entry)
+		if err != nil {
+			j.logger.Error("discarding-unreadable-entry", err, lager.Data{"file": file.Name()})
+			os.Remove(path)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (j *fileJournal) Save(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(j.dir, tempFilePrefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), j.path(entry.ID))
+}
+
+func (j *fileJournal) Remove(id string) error {
+	err := os.Remove(j.path(id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (j *fileJournal) path(id string) string {
+	return filepath.Join(j.dir, id+journalExtension)
+}
+
+type memoryJournal struct {
+	lock    sync.Mutex
+	entries map[string]Entry
+}
+
+// NewMemoryJournal keeps pending reports in memory only, so they are retried
+// but lost when the watcher exits.
+func NewMemoryJournal() Journal {
+	return &memoryJournal{entries: map[string]Entry{}}
+}
+
+func (j *memoryJournal) Load() ([]Entry, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	entries := make([]Entry, 0, len(j.entries))
+	for _, entry := range j.entries {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (j *memoryJournal) Save(entry Entry) error {
+	j.lock.Lock()
+	j.entries[entry.ID] = entry
+	j.lock.Unlock()
+	return nil
+}
+
+func (j *memoryJournal) Remove(id string) error {
+	j.lock.Lock()
+	delete(j.entries, id)
+	j.lock.Unlock()
+	return nil
+}
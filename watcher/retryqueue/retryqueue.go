@@ -0,0 +1,350 @@
+package retryqueue
+
+import (
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/runtimeschema/metric"
+	"code.cloudfoundry.org/tps/cc_client"
+	"code.cloudfoundry.org/workpool"
+	"github.com/nu7hatch/gouuid"
+)
+
+const (
+	DefaultMaxEntries     = 10000
+	DefaultMaxAge         = time.Hour
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = 5 * time.Minute
+	DefaultPollInterval   = time.Second
+	DefaultWorkers        = 10
+	DefaultMaxPerPoll     = 500
+)
+
+const (
+	crashReportRetries   = metric.Counter("TPSCrashReportRetries")
+	crashReportsDropped  = metric.Counter("TPSCrashReportsDropped")
+	crashReportsRejected = metric.Counter("TPSCrashReportsRejected")
+	crashReportsPending  = metric.Metric("TPSCrashReportsPending")
+)
+
+//go:generate counterfeiter -o fakes/fake_queue.go . Queue
+type Queue interface {
+	Enqueue(logger lager.Logger, processGuid string, request cc_messages.AppCrashedRequest)
+}
+
+type Config struct {
+	MaxEntries     int
+	MaxAge         time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	PollInterval   time.Duration
+
+	// Workers bounds how many reports are redelivered at once, and
+	// MaxPerPoll how many are redelivered per poll, oldest first.
+	Workers    int
+	MaxPerPoll int
+}
+
+// RetryQueue redelivers crash reports that Cloud Controller failed to accept,
+// backing off exponentially with jitter between attempts. Pending reports are
+// written to the journal so they outlive the process, and are dropped once
+// they are older than MaxAge or when the queue is over MaxEntries. Reports
+// Cloud Controller rejects outright are dropped at once.
+type RetryQueue struct {
+	logger   lager.Logger
+	journal  Journal
+	ccClient cc_client.CcClient
+	clock    clock.Clock
+	config   Config
+
+	lock    sync.Mutex
+	entries map[string]Entry
+}
+
+func New(logger lager.Logger, journal Journal, ccClient cc_client.CcClient, clock clock.Clock, config Config) *RetryQueue {
+	if config.Workers <= 0 {
+		config.Workers = DefaultWorkers
+	}
+	if config.MaxPerPoll <= 0 {
+		config.MaxPerPoll = DefaultMaxPerPoll
+	}
+
+	return &RetryQueue{
+		logger:   logger.Session("retry-queue"),
+		journal:  journal,
+		ccClient: ccClient,
+		clock:    clock,
+		config:   config,
+		entries:  map[string]Entry{},
+	}
+}
+
+func (q *RetryQueue) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := q.logger
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	entries, err := q.journal.Load()
+	if err != nil {
+		logger.Error("failed-loading-journal", err)
+		return err
+	}
+
+	q.lock.Lock()
+	for _, entry := range entries {
+		q.entries[entry.ID] = entry
+	}
+	q.trim(logger)
+	pending := len(q.entries)
+	q.lock.Unlock()
+
+	logger.Info("loaded-journal", lager.Data{"pending": pending})
+	crashReportsPending.Send(pending)
+
+	ticker := q.clock.NewTicker(q.config.PollInterval)
+	defer ticker.Stop()
+
+	close(ready)
+	logger.Info("started")
+
+	for {
+		select {
+		case <-ticker.C():
+			q.retryDue(logger)
+
+		case <-signals:
+			logger.Info("stopping")
+			return nil
+		}
+	}
+}
+
+func (q *RetryQueue) Enqueue(logger lager.Logger, processGuid string, request cc_messages.AppCrashedRequest) {
+	logger = logger.Session("enqueue-crash-report")
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		logger.Error("failed-generating-id", err)
+		return
+	}
+
+	now := q.clock.Now()
+	entry := Entry{
+		ID:          id.String(),
+		ProcessGuid: processGuid,
+		Request:     request,
+		EnqueuedAt:  now,
+		Attempts:    1,
+		NextAttempt: now.Add(q.backoff(1)),
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	err = q.journal.Save(entry)
+	if err != nil {
+		logger.Error("failed-journaling-entry", err)
+	}
+
+	q.entries[entry.ID] = entry
+	q.trim(logger)
+
+	logger.Info("enqueued", lager.Data{"id": entry.ID, "next-attempt": entry.NextAttempt})
+	crashReportsPending.Send(len(q.entries))
+}
+
+// Retryable reports whether a failed delivery may succeed later. Cloud
+// Controller rejecting a report, for example because the app has been
+// deleted, is permanent, apart from timeouts and rate limiting.
+func Retryable(err error) bool {
+	badResponse, ok := err.(*cc_client.BadResponseError)
+	if !ok {
+		return true
+	}
+
+	switch badResponse.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+
+	return badResponse.StatusCode < 400 || badResponse.StatusCode >= 500
+}
+
+func (q *RetryQueue) retryDue(logger lager.Logger) {
+	now := q.clock.Now()
+
+	due := q.due(now)
+	if len(due) > q.config.MaxPerPoll {
+		due = due[:q.config.MaxPerPoll]
+	}
+
+	if len(due) > 0 {
+		works := make([]func(), 0, len(due))
+		for _, entry := range due {
+			entry := entry
+			works = append(works, func() {
+				q.retry(logger, now, entry)
+			})
+		}
+
+		throttler, err := workpool.NewThrottler(q.config.Workers, works)
+		if err != nil {
+			logger.Error("failed-constructing-throttler", err, lager.Data{"max-workers": q.config.Workers, "num-works": len(works)})
+			return
+		}
+		throttler.Work()
+	}
+
+	q.lock.Lock()
+	crashReportsPending.Send(len(q.entries))
+	q.lock.Unlock()
+}
+
+func (q *RetryQueue) retry(logger lager.Logger, now time.Time, entry Entry) {
+	logger = logger.Session("retry", lager.Data{
+		"id":           entry.ID,
+		"process-guid": entry.ProcessGuid,
+		"index":        entry.Request.Index,
+		"attempts":     entry.Attempts,
+	})
+
+	if now.Sub(entry.EnqueuedAt) > q.config.MaxAge {
+		q.drop(logger, entry, "expired")
+		return
+	}
+
+	crashReportRetries.Increment()
+	err := q.ccClient.AppCrashed(entry.ProcessGuid, entry.Request, logger)
+	if err == nil {
+		logger.Info("delivered")
+		q.remove(logger, entry.ID)
+		return
+	}
+
+	if !Retryable(err) {
+		logger.Error("rejected", err)
+		crashReportsRejected.Increment()
+		q.remove(logger, entry.ID)
+		return
+	}
+
+	logger.Error("failed-delivering", err)
+	entry.Attempts++
+	entry.NextAttempt = now.Add(q.backoff(entry.Attempts))
+	q.update(logger, entry)
+}
+
+func (q *RetryQueue) due(now time.Time) []Entry {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	due := []Entry{}
+	for _, entry := range q.entries {
+		if !entry.NextAttempt.After(now) {
+			due = append(due, entry)
+		}
+	}
+
+	sort.Sort(byEnqueuedAt(due))
+	return due
+}
+
+func (q *RetryQueue) update(logger lager.Logger, entry Entry) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	// the entry may have been trimmed while it was being retried
+	if _, ok := q.entries[entry.ID]; !ok {
+		return
+	}
+
+	q.entries[entry.ID] = entry
+	err := q.journal.Save(entry)
+	if err != nil {
+		logger.Error("failed-journaling-entry", err)
+	}
+}
+
+func (q *RetryQueue) remove(logger lager.Logger, id string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.removeLocked(logger, id)
+}
+
+func (q *RetryQueue) drop(logger lager.Logger, entry Entry, reason string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.dropLocked(logger, entry, reason)
+}
+
+// trim drops the oldest entries until the queue is within MaxEntries.
+// The caller must hold the lock.
+func (q *RetryQueue) trim(logger lager.Logger) {
+	excess := len(q.entries) - q.config.MaxEntries
+	if excess <= 0 {
+		return
+	}
+
+	entries := make([]Entry, 0, len(q.entries))
+	for _, entry := range q.entries {
+		entries = append(entries, entry)
+	}
+	sort.Sort(byEnqueuedAt(entries))
+
+	for _, entry := range entries[:excess] {
+		q.dropLocked(logger, entry, "queue-full")
+	}
+}
+
+func (q *RetryQueue) dropLocked(logger lager.Logger, entry Entry, reason string) {
+	logger.Error("dropping-crash-report", nil, lager.Data{
+		"id":           entry.ID,
+		"process-guid": entry.ProcessGuid,
+		"index":        entry.Request.Index,
+		"attempts":     entry.Attempts,
+		"reason":       reason,
+	})
+
+	crashReportsDropped.Increment()
+	q.removeLocked(logger, entry.ID)
+}
+
+func (q *RetryQueue) removeLocked(logger lager.Logger, id string) {
+	delete(q.entries, id)
+
+	err := q.journal.Remove(id)
+	if err != nil {
+		logger.Error("failed-removing-journal-entry", err, lager.Data{"id": id})
+	}
+}
+
+// backoff doubles InitialBackoff for every failed attempt up to MaxBackoff and
+// then picks a random duration between half and all of it, so that reports
+// queued during the same outage do not all hit Cloud Controller at once.
+func (q *RetryQueue) backoff(attempts int) time.Duration {
+	backoff := q.config.InitialBackoff
+	for i := 1; i < attempts && backoff < q.config.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > q.config.MaxBackoff {
+		backoff = q.config.MaxBackoff
+	}
+
+	half := int64(backoff / 2)
+	return time.Duration(half + rand.Int63n(int64(backoff)-half+1))
+}
+
+type byEnqueuedAt []Entry
+
+func (e byEnqueuedAt) Len() int           { return len(e) }
+func (e byEnqueuedAt) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byEnqueuedAt) Less(i, j int) bool { return e[i].EnqueuedAt.Before(e[j].EnqueuedAt) }
@@ -0,0 +1,13 @@
+package retryqueue_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRetryQueue(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RetryQueue Suite")
+}
@@ -0,0 +1,269 @@
+package retryqueue_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/cc_client"
+	"code.cloudfoundry.org/tps/cc_client/fakes"
+	"code.cloudfoundry.org/tps/watcher/retryqueue"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryQueue", func() {
+	var (
+		logger     *lagertest.TestLogger
+		journalDir string
+		journal    retryqueue.Journal
+		ccClient   *fakes.FakeCcClient
+		fakeClock  *fakeclock.FakeClock
+		config     retryqueue.Config
+		queue      *retryqueue.RetryQueue
+		process    ifrit.Process
+
+		crashed cc_messages.AppCrashedRequest
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+
+		var err error
+		journalDir, err = ioutil.TempDir("", "retry-queue")
+		Expect(err).NotTo(HaveOccurred())
+
+		journal, err = retryqueue.NewFileJournal(logger, journalDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		ccClient = new(fakes.FakeCcClient)
+		fakeClock = fakeclock.NewFakeClock(time.Unix(1000, 0))
+
+		config = retryqueue.Config{
+			MaxEntries:     10,
+			MaxAge:         time.Minute,
+			InitialBackoff: time.Second,
+			MaxBackoff:     4 * time.Second,
+			PollInterval:   time.Second,
+		}
+
+		crashed = cc_messages.AppCrashedRequest{
+			Instance:        "instance-guid",
+			Index:           1,
+			Reason:          "CRASHED",
+			ExitDescription: "out of memory",
+			CrashCount:      2,
+			CrashTimestamp:  3,
+		}
+	})
+
+	JustBeforeEach(func() {
+		queue = retryqueue.New(logger, journal, ccClient, fakeClock, config)
+		process = ifrit.Invoke(queue)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		os.RemoveAll(journalDir)
+	})
+
+	loadJournal := func() []retryqueue.Entry {
+		entries, err := journal.Load()
+		Expect(err).NotTo(HaveOccurred())
+		return entries
+	}
+
+	It("journals an enqueued report and redelivers it after the backoff", func() {
+		queue.Enqueue(logger, "process-guid", crashed)
+
+		entries := loadJournal()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ProcessGuid).To(Equal("process-guid"))
+		Expect(entries[0].Request).To(Equal(crashed))
+		Expect(entries[0].Attempts).To(Equal(1))
+		Expect(entries[0].NextAttempt).To(BeTemporally(">=", fakeClock.Now().Add(config.InitialBackoff/2)))
+		Expect(entries[0].NextAttempt).To(BeTemporally("<=", fakeClock.Now().Add(config.InitialBackoff)))
+
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+		Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+		guid, request, _ := ccClient.AppCrashedArgsForCall(0)
+		Expect(guid).To(Equal("process-guid"))
+		Expect(request).To(Equal(crashed))
+
+		Eventually(loadJournal).Should(BeEmpty())
+	})
+
+	Context("when redelivery fails", func() {
+		BeforeEach(func() {
+			ccClient.AppCrashedReturns(errors.New("still down"))
+		})
+
+		It("backs off exponentially and keeps the report journaled", func() {
+			queue.Enqueue(logger, "process-guid", crashed)
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+
+			var entries []retryqueue.Entry
+			Eventually(func() int {
+				entries = loadJournal()
+				return entries[0].Attempts
+			}).Should(Equal(2))
+			Expect(entries[0].NextAttempt).To(BeTemporally(">=", fakeClock.Now().Add(time.Second)))
+			Expect(entries[0].NextAttempt).To(BeTemporally("<=", fakeClock.Now().Add(2*time.Second)))
+
+			fakeClock.Increment(2 * time.Second)
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(2))
+		})
+	})
+
+	Context("when the journal holds reports from a previous run", func() {
+		BeforeEach(func() {
+			Expect(journal.Save(retryqueue.Entry{
+				ID:          "pending",
+				ProcessGuid: "process-guid",
+				Request:     crashed,
+				EnqueuedAt:  time.Unix(990, 0),
+				Attempts:    3,
+				NextAttempt: time.Unix(995, 0),
+			})).To(Succeed())
+		})
+
+		It("redelivers them", func() {
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+			guid, request, _ := ccClient.AppCrashedArgsForCall(0)
+			Expect(guid).To(Equal("process-guid"))
+			Expect(request).To(Equal(crashed))
+
+			Eventually(loadJournal).Should(BeEmpty())
+		})
+	})
+
+	Context("when a save was interrupted before its temp file was renamed", func() {
+		var tempFile string
+
+		BeforeEach(func() {
+			file, err := ioutil.TempFile(journalDir, "entry")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			tempFile = file.Name()
+		})
+
+		It("removes the temp file when loading the journal", func() {
+			Expect(loadJournal()).To(BeEmpty())
+			Expect(tempFile).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("when Cloud Controller rejects the report", func() {
+		BeforeEach(func() {
+			ccClient.AppCrashedReturns(&cc_client.BadResponseError{StatusCode: 404})
+		})
+
+		It("drops it without retrying", func() {
+			queue.Enqueue(logger, "process-guid", crashed)
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+			Eventually(loadJournal).Should(BeEmpty())
+			Expect(logger).To(gbytes.Say("rejected"))
+
+			fakeClock.Increment(config.MaxBackoff)
+			Consistently(ccClient.AppCrashedCallCount).Should(Equal(1))
+		})
+	})
+
+	Context("when more reports are due than may be redelivered per poll", func() {
+		BeforeEach(func() {
+			config.MaxPerPoll = 2
+			config.Workers = 2
+		})
+
+		It("redelivers the oldest first and the rest on the next poll", func() {
+			for _, guid := range []string{"first", "second", "third"} {
+				queue.Enqueue(logger, guid, crashed)
+				fakeClock.Increment(time.Millisecond)
+			}
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(2))
+
+			guids := []string{}
+			for i := 0; i < 2; i++ {
+				guid, _, _ := ccClient.AppCrashedArgsForCall(i)
+				guids = append(guids, guid)
+			}
+			Expect(guids).To(ConsistOf("first", "second"))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(3))
+			guid, _, _ := ccClient.AppCrashedArgsForCall(2)
+			Expect(guid).To(Equal("third"))
+		})
+	})
+
+	Context("when a report is older than the max age", func() {
+		BeforeEach(func() {
+			Expect(journal.Save(retryqueue.Entry{
+				ID:          "stale",
+				ProcessGuid: "process-guid",
+				Request:     crashed,
+				EnqueuedAt:  time.Unix(100, 0),
+				Attempts:    20,
+				NextAttempt: time.Unix(995, 0),
+			})).To(Succeed())
+		})
+
+		It("drops it without redelivering", func() {
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+			Eventually(loadJournal).Should(BeEmpty())
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(0))
+			Expect(logger).To(gbytes.Say("dropping-crash-report"))
+		})
+	})
+
+	Context("when the queue is full", func() {
+		BeforeEach(func() {
+			config.MaxEntries = 2
+		})
+
+		It("drops the oldest reports", func() {
+			for _, guid := range []string{"first", "second", "third"} {
+				queue.Enqueue(logger, guid, crashed)
+				fakeClock.Increment(time.Millisecond)
+			}
+
+			entries := loadJournal()
+			Expect(entries).To(HaveLen(2))
+
+			guids := []string{entries[0].ProcessGuid, entries[1].ProcessGuid}
+			Expect(guids).To(ConsistOf("second", "third"))
+		})
+	})
+
+	Context("with an in-memory journal", func() {
+		BeforeEach(func() {
+			journal = retryqueue.NewMemoryJournal()
+		})
+
+		It("still redelivers", func() {
+			queue.Enqueue(logger, "process-guid", crashed)
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+			Eventually(loadJournal).Should(BeEmpty())
+		})
+	})
+})
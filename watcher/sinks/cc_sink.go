@@ -0,0 +1,62 @@
+package sinks
+
+import (
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/cc_client"
+	"code.cloudfoundry.org/tps/watcher/retryqueue"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+)
+
+type ccSink struct {
+	ccClient   cc_client.CcClient
+	retryQueue retryqueue.Queue
+}
+
+// NewCCSink records crashes with Cloud Controller. Crashes it fails to record
+// are handed to the retry queue, unless Cloud Controller rejected them.
+func NewCCSink(ccClient cc_client.CcClient, retryQueue retryqueue.Queue) Sink {
+	return &ccSink{
+		ccClient:   ccClient,
+		retryQueue: retryQueue,
+	}
+}
+
+func (s *ccSink) Send(logger lager.Logger, notification Notification) error {
+	if notification.Crash == nil {
+		return nil
+	}
+
+	err := s.ccClient.AppCrashed(notification.ProcessGuid, *notification.Crash, logger)
+	if err != nil && retryqueue.Retryable(err) {
+		s.retryQueue.Enqueue(logger, notification.ProcessGuid, *notification.Crash)
+	}
+	return err
+}
+
+// RetryUndelivered hands crashes that never reached the CC sink to the retry
+// queue. It is meant for the Undelivered of the CC sink's target.
+func RetryUndelivered(retryQueue retryqueue.Queue) func(lager.Logger, Notification) {
+	return func(logger lager.Logger, notification Notification) {
+		if notification.Crash == nil {
+			return
+		}
+
+		retryQueue.Enqueue(logger, notification.ProcessGuid, *notification.Crash)
+	}
+}
+
+type transitionSink struct {
+	client transitions.Client
+}
+
+func NewTransitionSink(client transitions.Client) Sink {
+	return &transitionSink{client: client}
+}
+
+func (s *transitionSink) Send(logger lager.Logger, notification Notification) error {
+	if notification.Transition == nil {
+		return nil
+	}
+
+	return s.client.Transitioned(*notification.Transition, logger)
+}
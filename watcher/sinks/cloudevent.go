@@ -0,0 +1,84 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"github.com/nu7hatch/gouuid"
+)
+
+const (
+	CloudEventSource      = "/tps-watcher"
+	CrashEventType        = "org.cloudfoundry.tps.app.crashed"
+	TransitionEventType   = "org.cloudfoundry.tps.app.transitioned"
+	cloudEventSpecVersion = "1.0"
+)
+
+// CloudEvent is the structured JSON encoding of a CloudEvents 1.0 event.
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject"`
+	Time            string      `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+type crashData struct {
+	ProcessGuid string `json:"process_guid"`
+	Domain      string `json:"domain"`
+	cc_messages.AppCrashedRequest
+}
+
+// newCloudEvent uses the notification's ID, so that redelivering a
+// notification produces the same event, and only makes one up for
+// notifications sent without one.
+func newCloudEvent(notification Notification, now time.Time) (CloudEvent, error) {
+	id := notification.ID
+	if id == "" {
+		generated, err := uuid.NewV4()
+		if err != nil {
+			return CloudEvent{}, err
+		}
+		id = generated.String()
+	}
+
+	event := CloudEvent{
+		SpecVersion:     cloudEventSpecVersion,
+		ID:              id,
+		Source:          CloudEventSource,
+		Subject:         notification.ProcessGuid,
+		Time:            now.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+	}
+
+	switch {
+	case notification.Crash != nil:
+		event.Type = CrashEventType
+		event.Data = crashData{
+			ProcessGuid:       notification.ProcessGuid,
+			Domain:            notification.Domain,
+			AppCrashedRequest: *notification.Crash,
+		}
+	case notification.Transition != nil:
+		event.Type = TransitionEventType
+		event.Data = notification.Transition
+	default:
+		return CloudEvent{}, fmt.Errorf("empty %s notification", notification.Kind)
+	}
+
+	return event, nil
+}
+
+func marshalCloudEvent(notification Notification, now time.Time) ([]byte, error) {
+	event, err := newCloudEvent(notification, now)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(event)
+}
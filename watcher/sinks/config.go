@@ -0,0 +1,131 @@
+package sinks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+const (
+	CCSinkName         = "cc"
+	TransitionSinkName = "transitions"
+
+	DefaultAttempts      = 3
+	DefaultRetryInterval = time.Second
+	DefaultQueueSize     = 1000
+)
+
+// Config describes one sink in the -sinksConfigFile, which holds a JSON
+// array of them. Events defaults to both crashes and transitions.
+type Config struct {
+	Name          string   `json:"name"`
+	Type          string   `json:"type"`
+	Events        []string `json:"events,omitempty"`
+	Attempts      int      `json:"attempts,omitempty"`
+	RetryInterval string   `json:"retry_interval,omitempty"`
+	QueueSize     int      `json:"queue_size,omitempty"`
+
+	// webhook
+	URL            string `json:"url,omitempty"`
+	Secret         string `json:"secret,omitempty"`
+	SkipCertVerify bool   `json:"skip_cert_verify,omitempty"`
+
+	// file
+	Path string `json:"path,omitempty"`
+
+	// syslog
+	Network  string `json:"network,omitempty"`
+	Address  string `json:"address,omitempty"`
+	Facility *int   `json:"facility,omitempty"`
+}
+
+func LoadConfig(path string) ([]Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := []Config{}
+	err = json.Unmarshal(data, &configs)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{CCSinkName: true, TransitionSinkName: true}
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("sink of type %q has no name", config.Type)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("duplicate sink name %q", config.Name)
+		}
+		names[config.Name] = true
+	}
+
+	return configs, nil
+}
+
+func NewTarget(config Config, clock clock.Clock) (Target, error) {
+	target := Target{
+		Name:          config.Name,
+		Kinds:         config.Events,
+		Attempts:      config.Attempts,
+		RetryInterval: DefaultRetryInterval,
+		QueueSize:     config.QueueSize,
+	}
+
+	if len(target.Kinds) == 0 {
+		target.Kinds = []string{CrashKind, TransitionKind}
+	}
+	for _, kind := range target.Kinds {
+		if kind != CrashKind && kind != TransitionKind {
+			return Target{}, fmt.Errorf("sink %q: unknown event kind %q", config.Name, kind)
+		}
+	}
+
+	if target.Attempts == 0 {
+		target.Attempts = DefaultAttempts
+	}
+
+	if config.RetryInterval != "" {
+		interval, err := time.ParseDuration(config.RetryInterval)
+		if err != nil {
+			return Target{}, fmt.Errorf("sink %q: invalid retry_interval: %s", config.Name, err)
+		}
+		target.RetryInterval = interval
+	}
+
+	var err error
+	switch config.Type {
+	case "webhook":
+		if config.URL == "" || config.Secret == "" {
+			return Target{}, fmt.Errorf("sink %q: webhook requires a url and a secret", config.Name)
+		}
+		target.Sink = NewWebhookSink(config.URL, config.Secret, config.SkipCertVerify, clock)
+
+	case "file":
+		if config.Path == "" {
+			return Target{}, fmt.Errorf("sink %q: file requires a path", config.Name)
+		}
+		target.Sink, err = NewFileSink(config.Path, clock)
+
+	case "syslog":
+		facility := DefaultSyslogFacility
+		if config.Facility != nil {
+			facility = *config.Facility
+		}
+		target.Sink, err = NewSyslogSink(config.Network, config.Address, facility, clock)
+
+	default:
+		return Target{}, fmt.Errorf("sink %q: unknown type %q", config.Name, config.Type)
+	}
+
+	if err != nil {
+		return Target{}, fmt.Errorf("sink %q: %s", config.Name, err)
+	}
+
+	return target, nil
+}
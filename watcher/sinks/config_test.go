@@ -0,0 +1,76 @@
+package sinks_test
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	Describe("LoadConfig", func() {
+		var path string
+
+		writeFile := func(contents string) {
+			file, err := ioutil.TempFile("", "sinks-config")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = file.WriteString(contents)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			path = file.Name()
+		}
+
+		AfterEach(func() {
+			os.Remove(path)
+		})
+
+		It("loads the sinks", func() {
+			writeFile(`[
+				{"name": "sre", "type": "webhook", "url": "https://example.com", "secret": "s", "events": ["crash"]},
+				{"name": "siem", "type": "syslog", "network": "udp", "address": "127.0.0.1:514", "attempts": 1}
+			]`)
+
+			configs, err := sinks.LoadConfig(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configs).To(HaveLen(2))
+			Expect(configs[0].Events).To(Equal([]string{sinks.CrashKind}))
+			Expect(configs[1].Attempts).To(Equal(1))
+		})
+
+		It("rejects duplicate and reserved names", func() {
+			writeFile(`[{"name": "cc", "type": "file", "path": "/tmp/events"}]`)
+			_, err := sinks.LoadConfig(path)
+			Expect(err).To(MatchError(ContainSubstring(`"cc"`)))
+		})
+	})
+
+	Describe("NewTarget", func() {
+		clock := fakeclock.NewFakeClock(time.Now())
+
+		It("defaults to all events and a few attempts", func() {
+			target, err := sinks.NewTarget(sinks.Config{Name: "sre", Type: "webhook", URL: "https://example.com", Secret: "s"}, clock)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(target.Kinds).To(ConsistOf(sinks.CrashKind, sinks.TransitionKind))
+			Expect(target.Attempts).To(Equal(sinks.DefaultAttempts))
+			Expect(target.RetryInterval).To(Equal(sinks.DefaultRetryInterval))
+		})
+
+		It("requires a secret for webhooks", func() {
+			_, err := sinks.NewTarget(sinks.Config{Name: "sre", Type: "webhook", URL: "https://example.com"}, clock)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects unknown types and events", func() {
+			_, err := sinks.NewTarget(sinks.Config{Name: "x", Type: "pager"}, clock)
+			Expect(err).To(MatchError(ContainSubstring("unknown type")))
+
+			_, err = sinks.NewTarget(sinks.Config{Name: "x", Type: "syslog", Network: "udp", Events: []string{"deploy"}}, clock)
+			Expect(err).To(MatchError(ContainSubstring("unknown event kind")))
+		})
+	})
+})
@@ -0,0 +1,56 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+)
+
+type FakeSink struct {
+	SendStub        func(logger lager.Logger, notification sinks.Notification) error
+	sendMutex       sync.RWMutex
+	sendArgsForCall []struct {
+		logger       lager.Logger
+		notification sinks.Notification
+	}
+	sendReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeSink) Send(logger lager.Logger, notification sinks.Notification) error {
+	fake.sendMutex.Lock()
+	fake.sendArgsForCall = append(fake.sendArgsForCall, struct {
+		logger       lager.Logger
+		notification sinks.Notification
+	}{logger, notification})
+	fake.sendMutex.Unlock()
+	if fake.SendStub != nil {
+		return fake.SendStub(logger, notification)
+	} else {
+		return fake.sendReturns.result1
+	}
+}
+
+func (fake *FakeSink) SendCallCount() int {
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	return len(fake.sendArgsForCall)
+}
+
+func (fake *FakeSink) SendArgsForCall(i int) (lager.Logger, sinks.Notification) {
+	fake.sendMutex.RLock()
+	defer fake.sendMutex.RUnlock()
+	return fake.sendArgsForCall[i].logger, fake.sendArgsForCall[i].notification
+}
+
+func (fake *FakeSink) SendReturns(result1 error) {
+	fake.SendStub = nil
+	fake.sendReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ sinks.Sink = new(FakeSink)
@@ -0,0 +1,43 @@
+package sinks
+
+import (
+	"os"
+	"sync"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+type fileSink struct {
+	clock clock.Clock
+
+	lock sync.Mutex
+	file *os.File
+}
+
+// NewFileSink appends every notification to path as one CloudEvents JSON
+// object per line.
+func NewFileSink(path string, clock clock.Clock) (Sink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSink{
+		clock: clock,
+		file:  file,
+	}, nil
+}
+
+func (s *fileSink) Send(logger lager.Logger, notification Notification) error {
+	line, err := marshalCloudEvent(notification, s.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,72 @@
+package sinks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FileSink", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "file-sink")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "events.jsonl")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("appends one CloudEvent per line", func() {
+		sink, err := sinks.NewFileSink(path, fakeclock.NewFakeClock(time.Unix(1000, 0)))
+		Expect(err).NotTo(HaveOccurred())
+
+		logger := lagertest.NewTestLogger("test")
+		Expect(sink.Send(logger, sinks.Notification{
+			Kind:        sinks.CrashKind,
+			ProcessGuid: "process-guid",
+			Crash:       &cc_messages.AppCrashedRequest{Index: 1},
+		})).To(Succeed())
+		Expect(sink.Send(logger, sinks.Notification{
+			Kind:        sinks.TransitionKind,
+			ProcessGuid: "process-guid",
+			Transition:  &transitions.Transition{Event: transitions.Changed, To: cc_messages.LRPInstanceStateRunning},
+		})).To(Succeed())
+
+		contents, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+		Expect(lines).To(HaveLen(2))
+
+		var crash, transition sinks.CloudEvent
+		Expect(json.Unmarshal([]byte(lines[0]), &crash)).To(Succeed())
+		Expect(json.Unmarshal([]byte(lines[1]), &transition)).To(Succeed())
+		Expect(crash.Type).To(Equal(sinks.CrashEventType))
+		Expect(transition.Type).To(Equal(sinks.TransitionEventType))
+		Expect(transition.Data).To(HaveKeyWithValue("to", "RUNNING"))
+	})
+
+	It("fails when the file cannot be opened", func() {
+		_, err := sinks.NewFileSink(filepath.Join(dir, "missing", "events.jsonl"), fakeclock.NewFakeClock(time.Now()))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,249 @@
+package sinks
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/runtimeschema/metric"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+	"github.com/nu7hatch/gouuid"
+)
+
+const (
+	CrashKind      = "crash"
+	TransitionKind = "transition"
+)
+
+var ErrQueueFull = errors.New("sink queue is full")
+
+// Notification is something the watcher observed about an app instance.
+// Exactly one of Crash and Transition is set, depending on Kind. When Targets
+// is not empty, only the targets it names receive the notification. ID
+// identifies the notification to sinks that report one.
+type Notification struct {
+	ID          string
+	Kind        string
+	ProcessGuid string
+	Domain      string
+	Targets     []string
+	Crash       *cc_messages.AppCrashedRequest
+	Transition  *transitions.Transition
+}
+
+//go:generate counterfeiter -o fakes/fake_sink.go . Sink
+type Sink interface {
+	Send(logger lager.Logger, notification Notification) error
+}
+
+type Notifier interface {
+	Wants(kind string) bool
+	Notify(logger lager.Logger, notification Notification)
+}
+
+// Target is a sink together with the notifications it receives, how often
+// delivery to it is attempted before giving up, how many notifications may
+// wait for it and how many are delivered to it at once. Undelivered, if set,
+// is handed the notifications that are dropped because the queue is full or
+// that are still queued at shutdown.
+type Target struct {
+	Name          string
+	Sink          Sink
+	Kinds         []string
+	Attempts      int
+	RetryInterval time.Duration
+	QueueSize     int
+	Workers       int
+	Undelivered   func(logger lager.Logger, notification Notification)
+}
+
+func (t Target) wants(kind string) bool {
+	for _, k := range t.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Target) selected(notification Notification) bool {
+	if !t.wants(notification.Kind) {
+		return false
+	}
+
+	if len(notification.Targets) == 0 {
+		return true
+	}
+
+	for _, name := range notification.Targets {
+		if name == t.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Fanout delivers every notification to each target that wants it. Each
+// target has its own bounded queue and workers, so a slow or failing target
+// neither holds up the others nor the caller; notifications for a target
+// whose queue is full are dropped.
+type Fanout struct {
+	targets []Target
+	queues  []chan queuedNotification
+}
+
+type queuedNotification struct {
+	logger       lager.Logger
+	notification Notification
+}
+
+func NewFanout(targets ...Target) *Fanout {
+	queues := make([]chan queuedNotification, len(targets))
+	for i, target := range targets {
+		queueSize := target.QueueSize
+		if queueSize < 1 {
+			queueSize = DefaultQueueSize
+		}
+		queues[i] = make(chan queuedNotification, queueSize)
+	}
+
+	return &Fanout{targets: targets, queues: queues}
+}
+
+func (f *Fanout) Names() []string {
+	names := make([]string, 0, len(f.targets))
+	for _, target := range f.targets {
+		names = append(names, target.Name)
+	}
+	return names
+}
+
+func (f *Fanout) Wants(kind string) bool {
+	for _, target := range f.targets {
+		if target.wants(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify queues the notification for every selected target without waiting
+// for it to be delivered. The notification is given an ID once, here, so
+// that every target and every retry reports it under the same ID.
+func (f *Fanout) Notify(logger lager.Logger, notification Notification) {
+	if notification.ID == "" {
+		id, err := uuid.NewV4()
+		if err != nil {
+			logger.Error("failed-generating-notification-id", err)
+		} else {
+			notification.ID = id.String()
+		}
+	}
+
+	for i, target := range f.targets {
+		if !target.selected(notification) {
+			continue
+		}
+
+		targetLogger := logger.Session("sink", lager.Data{"sink": target.Name})
+		select {
+		case f.queues[i] <- queuedNotification{logger: targetLogger, notification: notification}:
+		default:
+			targetLogger.Error("dropped-notification", ErrQueueFull, lager.Data{"kind": notification.Kind})
+			metric.Counter("TPSSinkDropped." + target.Name).Increment()
+			if target.Undelivered != nil {
+				target.Undelivered(targetLogger, notification)
+			}
+		}
+	}
+}
+
+// Run delivers queued notifications until signalled. Notifications still
+// queued when it is signalled are not delivered, but handed to their target's
+// Undelivered.
+func (f *Fanout) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	done := make(chan struct{})
+
+	wg := sync.WaitGroup{}
+	for i, target := range f.targets {
+		workers := target.Workers
+		if workers < 1 {
+			workers = 1
+		}
+
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(target Target, queue <-chan queuedNotification) {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+
+					select {
+					case queued := <-queue:
+						deliver(queued.logger, target, queued.notification, done)
+					case <-done:
+						return
+					}
+				}
+			}(target, f.queues[i])
+		}
+	}
+
+	close(ready)
+
+	<-signals
+	close(done)
+	wg.Wait()
+
+	for i, target := range f.targets {
+		f.drain(target, f.queues[i])
+	}
+
+	return nil
+}
+
+func (f *Fanout) drain(target Target, queue <-chan queuedNotification) {
+	for {
+		select {
+		case queued := <-queue:
+			if target.Undelivered != nil {
+				target.Undelivered(queued.logger, queued.notification)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func deliver(logger lager.Logger, target Target, notification Notification, done <-chan struct{}) {
+	attempts := target.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = target.Sink.Send(logger, notification)
+		if err == nil {
+			return
+		}
+
+		logger.Error("failed-sending-notification", err, lager.Data{"attempt": attempt, "kind": notification.Kind})
+		if attempt < attempts {
+			select {
+			case <-time.After(target.RetryInterval):
+			case <-done:
+				return
+			}
+		}
+	}
+
+	metric.Counter("TPSSinkFailures." + target.Name).Increment()
+}
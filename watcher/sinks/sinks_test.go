@@ -0,0 +1,282 @@
+package sinks_test
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/cc_client"
+	ccfakes "code.cloudfoundry.org/tps/cc_client/fakes"
+	retryqueuefakes "code.cloudfoundry.org/tps/watcher/retryqueue/fakes"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+	"code.cloudfoundry.org/tps/watcher/sinks/fakes"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sinks", func() {
+	var (
+		logger *lagertest.TestLogger
+		crash  sinks.Notification
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		crash = sinks.Notification{
+			Kind:        sinks.CrashKind,
+			ProcessGuid: "process-guid",
+			Domain:      cc_messages.AppLRPDomain,
+			Crash:       &cc_messages.AppCrashedRequest{Instance: "instance-guid", Index: 1, CrashCount: 2},
+		}
+	})
+
+	Describe("Fanout", func() {
+		var crashSink, allSink, transitionSink *fakes.FakeSink
+		var crashTarget sinks.Target
+		var fanout *sinks.Fanout
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			crashSink = new(fakes.FakeSink)
+			allSink = new(fakes.FakeSink)
+			transitionSink = new(fakes.FakeSink)
+
+			crashTarget = sinks.Target{Name: "crashes", Sink: crashSink, Kinds: []string{sinks.CrashKind}, Attempts: 2}
+		})
+
+		JustBeforeEach(func() {
+			fanout = sinks.NewFanout(
+				crashTarget,
+				sinks.Target{Name: "all", Sink: allSink, Kinds: []string{sinks.CrashKind, sinks.TransitionKind}},
+				sinks.Target{Name: "transitions", Sink: transitionSink, Kinds: []string{sinks.TransitionKind}},
+			)
+			process = ifrit.Invoke(fanout)
+		})
+
+		AfterEach(func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive())
+		})
+
+		It("reports which kinds of notifications its targets want", func() {
+			Expect(fanout.Wants(sinks.CrashKind)).To(BeTrue())
+			Expect(fanout.Wants(sinks.TransitionKind)).To(BeTrue())
+			Expect(sinks.NewFanout().Wants(sinks.CrashKind)).To(BeFalse())
+		})
+
+		It("sends a notification to every target that wants it", func() {
+			fanout.Notify(logger, crash)
+
+			Eventually(crashSink.SendCallCount).Should(Equal(1))
+			Eventually(allSink.SendCallCount).Should(Equal(1))
+			Consistently(transitionSink.SendCallCount).Should(Equal(0))
+
+			_, notification := crashSink.SendArgsForCall(0)
+			Expect(notification.ID).NotTo(BeEmpty())
+			notification.ID = ""
+			Expect(notification).To(Equal(crash))
+		})
+
+		It("names its targets", func() {
+			Expect(fanout.Names()).To(Equal([]string{"crashes", "all", "transitions"}))
+		})
+
+		Context("when the notification names its targets", func() {
+			BeforeEach(func() {
+				crash.Targets = []string{"all"}
+			})
+
+			It("sends it only to those targets", func() {
+				fanout.Notify(logger, crash)
+
+				Eventually(allSink.SendCallCount).Should(Equal(1))
+				Consistently(crashSink.SendCallCount).Should(Equal(0))
+			})
+		})
+
+		Context("when a target fails", func() {
+			BeforeEach(func() {
+				crashSink.SendReturns(errors.New("boom"))
+			})
+
+			It("retries it up to its attempts under the same ID without affecting the others", func() {
+				fanout.Notify(logger, crash)
+
+				Eventually(crashSink.SendCallCount).Should(Equal(2))
+				Consistently(crashSink.SendCallCount).Should(Equal(2))
+				Eventually(allSink.SendCallCount).Should(Equal(1))
+				Expect(logger).To(gbytes.Say(`"sink":"crashes"`))
+
+				_, first := crashSink.SendArgsForCall(0)
+				_, second := crashSink.SendArgsForCall(1)
+				Expect(second.ID).To(Equal(first.ID))
+			})
+		})
+
+		Context("when a target is slow", func() {
+			var unblock chan struct{}
+			var release func()
+			var undelivered chan sinks.Notification
+
+			BeforeEach(func() {
+				unblock = make(chan struct{})
+				once := &sync.Once{}
+				release = func() { once.Do(func() { close(unblock) }) }
+				crashSink.SendStub = func(lager.Logger, sinks.Notification) error {
+					<-unblock
+					return nil
+				}
+				crashTarget.QueueSize = 1
+
+				undelivered = make(chan sinks.Notification, 10)
+				crashTarget.Undelivered = func(_ lager.Logger, notification sinks.Notification) {
+					undelivered <- notification
+				}
+			})
+
+			AfterEach(func() {
+				release()
+			})
+
+			It("does not wait for it, and drops what does not fit in its queue", func() {
+				fanout.Notify(logger, crash)
+				Eventually(crashSink.SendCallCount).Should(Equal(1))
+
+				fanout.Notify(logger, crash)
+				fanout.Notify(logger, crash)
+
+				Eventually(allSink.SendCallCount).Should(Equal(3))
+				Expect(logger).To(gbytes.Say("dropped-notification"))
+
+				unblock <- struct{}{}
+				Eventually(crashSink.SendCallCount).Should(Equal(2))
+				unblock <- struct{}{}
+				Consistently(crashSink.SendCallCount).Should(Equal(2))
+			})
+
+			It("hands what it drops and what is still queued at shutdown to Undelivered", func() {
+				fanout.Notify(logger, crash)
+				Eventually(crashSink.SendCallCount).Should(Equal(1))
+
+				fanout.Notify(logger, crash)
+				fanout.Notify(logger, crash)
+				Eventually(undelivered).Should(Receive())
+
+				process.Signal(os.Interrupt)
+				release()
+				Eventually(process.Wait()).Should(Receive())
+
+				Expect(crashSink.SendCallCount() + len(undelivered)).To(Equal(2))
+			})
+		})
+
+		Context("when a target has several workers", func() {
+			var unblock chan struct{}
+
+			BeforeEach(func() {
+				unblock = make(chan struct{})
+				crashSink.SendStub = func(lager.Logger, sinks.Notification) error {
+					<-unblock
+					return nil
+				}
+				crashTarget.Workers = 2
+			})
+
+			AfterEach(func() {
+				close(unblock)
+			})
+
+			It("delivers to it concurrently", func() {
+				fanout.Notify(logger, crash)
+				fanout.Notify(logger, crash)
+
+				Eventually(crashSink.SendCallCount).Should(Equal(2))
+			})
+		})
+	})
+
+	Describe("CC sink", func() {
+		var (
+			ccClient   *ccfakes.FakeCcClient
+			retryQueue *retryqueuefakes.FakeQueue
+			sink       sinks.Sink
+		)
+
+		BeforeEach(func() {
+			ccClient = new(ccfakes.FakeCcClient)
+			retryQueue = new(retryqueuefakes.FakeQueue)
+			sink = sinks.NewCCSink(ccClient, retryQueue)
+		})
+
+		It("records crashes with Cloud Controller", func() {
+			Expect(sink.Send(logger, crash)).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(1))
+			guid, crashed, _ := ccClient.AppCrashedArgsForCall(0)
+			Expect(guid).To(Equal("process-guid"))
+			Expect(crashed).To(Equal(*crash.Crash))
+		})
+
+		It("ignores transitions", func() {
+			Expect(sink.Send(logger, sinks.Notification{
+				Kind:       sinks.TransitionKind,
+				Transition: &transitions.Transition{},
+			})).To(Succeed())
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(0))
+		})
+
+		Context("when Cloud Controller fails", func() {
+			BeforeEach(func() {
+				ccClient.AppCrashedReturns(errors.New("cc is down"))
+			})
+
+			It("enqueues the crash for retry", func() {
+				Expect(sink.Send(logger, crash)).To(MatchError("cc is down"))
+
+				Expect(retryQueue.EnqueueCallCount()).To(Equal(1))
+				_, guid, crashed := retryQueue.EnqueueArgsForCall(0)
+				Expect(guid).To(Equal("process-guid"))
+				Expect(crashed).To(Equal(*crash.Crash))
+			})
+		})
+
+		Describe("RetryUndelivered", func() {
+			It("enqueues undelivered crashes for retry", func() {
+				sinks.RetryUndelivered(retryQueue)(logger, crash)
+
+				Expect(retryQueue.EnqueueCallCount()).To(Equal(1))
+				_, guid, crashed := retryQueue.EnqueueArgsForCall(0)
+				Expect(guid).To(Equal("process-guid"))
+				Expect(crashed).To(Equal(*crash.Crash))
+			})
+
+			It("ignores transitions", func() {
+				sinks.RetryUndelivered(retryQueue)(logger, sinks.Notification{
+					Kind:       sinks.TransitionKind,
+					Transition: &transitions.Transition{},
+				})
+
+				Expect(retryQueue.EnqueueCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when Cloud Controller rejects the crash", func() {
+			BeforeEach(func() {
+				ccClient.AppCrashedReturns(&cc_client.BadResponseError{StatusCode: 404})
+			})
+
+			It("does not enqueue it for retry", func() {
+				Expect(sink.Send(logger, crash)).To(HaveOccurred())
+				Expect(retryQueue.EnqueueCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
@@ -0,0 +1,109 @@
+package sinks
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	DefaultSyslogFacility = 16 // local0
+	syslogAppName         = "tps-watcher"
+	syslogDialTimeout     = 5 * time.Second
+	syslogWriteTimeout    = 5 * time.Second
+	syslogTimestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+	severityWarning = 4
+	severityNotice  = 5
+)
+
+type syslogSink struct {
+	network  string
+	address  string
+	facility int
+	hostname string
+	clock    clock.Clock
+
+	lock sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSink sends notifications as RFC 5424 messages whose MSG is the
+// CloudEvents JSON. Over udp each message is one datagram; over tcp messages
+// are framed by octet counting as in RFC 6587. The connection is redialed
+// after a failed write.
+func NewSyslogSink(network, address string, facility int, clock clock.Clock) (Sink, error) {
+	switch network {
+	case "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network %q", network)
+	}
+
+	if facility < 0 || facility > 23 {
+		return nil, fmt.Errorf("invalid syslog facility %d", facility)
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+
+	return &syslogSink{
+		network:  network,
+		address:  address,
+		facility: facility,
+		hostname: hostname,
+		clock:    clock,
+	}, nil
+}
+
+func (s *syslogSink) Send(logger lager.Logger, notification Notification) error {
+	now := s.clock.Now()
+	event, err := marshalCloudEvent(notification, now)
+	if err != nil {
+		return err
+	}
+
+	severity := severityNotice
+	if notification.Kind == CrashKind {
+		severity = severityWarning
+	}
+
+	message := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		s.facility*8+severity,
+		now.UTC().Format(syslogTimestampFormat),
+		s.hostname,
+		syslogAppName,
+		os.Getpid(),
+		notification.Kind,
+		event,
+	)
+	if s.network == "tcp" {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.conn == nil {
+		s.conn, err = net.DialTimeout(s.network, s.address, syslogDialTimeout)
+		if err != nil {
+			s.conn = nil
+			return err
+		}
+	}
+
+	s.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	_, err = s.conn.Write([]byte(message))
+	if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	return err
+}
@@ -0,0 +1,70 @@
+package sinks_test
+
+import (
+	"bufio"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyslogSink", func() {
+	var notification sinks.Notification
+
+	BeforeEach(func() {
+		notification = sinks.Notification{
+			Kind:        sinks.CrashKind,
+			ProcessGuid: "process-guid",
+			Crash:       &cc_messages.AppCrashedRequest{Index: 1},
+		}
+	})
+
+	It("sends an RFC 5424 message per datagram over udp", func() {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		sink, err := sinks.NewSyslogSink("udp", conn.LocalAddr().String(), sinks.DefaultSyslogFacility, fakeclock.NewFakeClock(time.Unix(1000, 0)))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(sink.Send(lagertest.NewTestLogger("test"), notification)).To(Succeed())
+
+		buffer := make([]byte, 65536)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buffer)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(string(buffer[:n])).To(MatchRegexp(`^<132>1 1970-01-01T00:16:40.000000Z \S+ tps-watcher \d+ crash - \{.*"type":"org.cloudfoundry.tps.app.crashed".*\}$`))
+	})
+
+	It("frames messages by octet counting over tcp", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close()
+
+		sink, err := sinks.NewSyslogSink("tcp", listener.Addr().String(), 1, fakeclock.NewFakeClock(time.Unix(1000, 0)))
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(sink.Send(lagertest.NewTestLogger("test"), notification)).To(Succeed())
+
+		conn, err := listener.Accept()
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		length, err := bufio.NewReader(conn).ReadString(' ')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(length).To(MatchRegexp(`^\d+ $`))
+	})
+
+	It("rejects unsupported networks", func() {
+		_, err := sinks.NewSyslogSink("unixgram", "/dev/log", sinks.DefaultSyslogFacility, fakeclock.NewFakeClock(time.Now()))
+		Expect(err).To(HaveOccurred())
+	})
+})
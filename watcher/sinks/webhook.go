@@ -0,0 +1,98 @@
+package sinks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	SignatureHeader       = "X-TPS-Signature"
+	webhookRequestTimeout = 5 * time.Second
+)
+
+type webhookSink struct {
+	url        string
+	secret     []byte
+	clock      clock.Clock
+	httpClient *http.Client
+}
+
+type BadResponseError struct {
+	StatusCode int
+}
+
+func (b *BadResponseError) Error() string {
+	return fmt.Sprintf("Webhook POST failed with %d", b.StatusCode)
+}
+
+// NewWebhookSink POSTs notifications as structured CloudEvents JSON. The
+// body is signed with HMAC-SHA256 using secret, and the signature is sent as
+// "sha256=<hex>" in the X-TPS-Signature header.
+func NewWebhookSink(url string, secret string, skipCertVerify bool, clock clock.Clock) Sink {
+	httpClient := &http.Client{
+		Timeout: webhookRequestTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: skipCertVerify,
+				MinVersion:         tls.VersionTLS10,
+			},
+		},
+	}
+
+	return &webhookSink{
+		url:        url,
+		secret:     []byte(secret),
+		clock:      clock,
+		httpClient: httpClient,
+	}
+}
+
+func (s *webhookSink) Send(logger lager.Logger, notification Notification) error {
+	payload, err := marshalCloudEvent(notification, s.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("content-type", "application/cloudevents+json")
+	request.Header.Set(SignatureHeader, Sign(s.secret, payload))
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &BadResponseError{response.StatusCode}
+	}
+
+	return nil
+}
+
+func Sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,95 @@
+package sinks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebhookSink", func() {
+	var (
+		server       *ghttp.Server
+		sink         sinks.Sink
+		notification sinks.Notification
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		sink = sinks.NewWebhookSink(server.URL()+"/events", "secret", false, fakeclock.NewFakeClock(time.Unix(1000, 0)))
+
+		notification = sinks.Notification{
+			Kind:        sinks.CrashKind,
+			ProcessGuid: "process-guid",
+			Domain:      cc_messages.AppLRPDomain,
+			Crash:       &cc_messages.AppCrashedRequest{Instance: "instance-guid", Index: 1, Reason: "CRASHED", CrashCount: 2},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("posts a signed CloudEvent", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/events"),
+			ghttp.VerifyContentType("application/cloudevents+json"),
+			func(w http.ResponseWriter, r *http.Request) {
+				body, err := ioutil.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(r.Header.Get(sinks.SignatureHeader)).To(Equal(sinks.Sign([]byte("secret"), body)))
+
+				var event map[string]interface{}
+				Expect(json.Unmarshal(body, &event)).To(Succeed())
+				Expect(event["specversion"]).To(Equal("1.0"))
+				Expect(event["type"]).To(Equal(sinks.CrashEventType))
+				Expect(event["source"]).To(Equal(sinks.CloudEventSource))
+				Expect(event["subject"]).To(Equal("process-guid"))
+				Expect(event["time"]).To(Equal("1970-01-01T00:16:40Z"))
+				Expect(event["id"]).NotTo(BeEmpty())
+				Expect(event["data"]).To(HaveKeyWithValue("process_guid", "process-guid"))
+				Expect(event["data"]).To(HaveKeyWithValue("instance", "instance-guid"))
+				Expect(event["data"]).To(HaveKeyWithValue("crash_count", BeNumerically("==", 2)))
+			},
+			ghttp.RespondWith(http.StatusNoContent, nil),
+		))
+
+		Expect(sink.Send(lagertest.NewTestLogger("test"), notification)).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("reuses the notification's ID across deliveries", func() {
+		notification.ID = "notification-id"
+
+		ids := []interface{}{}
+		recordID := func(w http.ResponseWriter, r *http.Request) {
+			var event map[string]interface{}
+			Expect(json.NewDecoder(r.Body).Decode(&event)).To(Succeed())
+			ids = append(ids, event["id"])
+		}
+		server.AppendHandlers(
+			ghttp.CombineHandlers(recordID, ghttp.RespondWith(http.StatusBadGateway, nil)),
+			ghttp.CombineHandlers(recordID, ghttp.RespondWith(http.StatusNoContent, nil)),
+		)
+
+		Expect(sink.Send(lagertest.NewTestLogger("test"), notification)).NotTo(Succeed())
+		Expect(sink.Send(lagertest.NewTestLogger("test"), notification)).To(Succeed())
+		Expect(ids).To(Equal([]interface{}{"notification-id", "notification-id"}))
+	})
+
+	It("fails on a non-2xx response", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusBadGateway, nil))
+
+		err := sink.Send(lagertest.NewTestLogger("test"), notification)
+		Expect(err).To(Equal(&sinks.BadResponseError{StatusCode: http.StatusBadGateway}))
+	})
+})
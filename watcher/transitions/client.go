@@ -0,0 +1,90 @@
+package transitions
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const requestTimeout = 5 * time.Second
+
+//go:generate counterfeiter -o fakes/fake_client.go . Client
+type Client interface {
+	Transitioned(transition Transition, logger lager.Logger) error
+}
+
+type client struct {
+	url        string
+	httpClient *http.Client
+}
+
+type BadResponseError struct {
+	StatusCode int
+}
+
+func (b *BadResponseError) Error() string {
+	return fmt.Sprintf("Transition POST failed with %d", b.StatusCode)
+}
+
+// NewClient posts every transition as JSON to url. Credentials in the url's
+// user info are sent as basic auth.
+func NewClient(url string, skipCertVerify bool) Client {
+	httpClient := &http.Client{
+		Timeout: requestTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			Dial: (&net.Dialer{
+				Timeout:   10 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).Dial,
+			TLSHandshakeTimeout: 10 * time.Second,
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: skipCertVerify,
+				MinVersion:         tls.VersionTLS10,
+			},
+		},
+	}
+
+	return &client{
+		url:        url,
+		httpClient: httpClient,
+	}
+}
+
+func (c *client) Transitioned(transition Transition, logger lager.Logger) error {
+	logger = logger.Session("transition-client")
+	logger.Debug("delivering-transition", lager.Data{"transition": transition})
+
+	payload, err := json.Marshal(transition)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", c.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("content-type", "application/json")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		logger.Error("deliver-transition-failed", err)
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return &BadResponseError{response.StatusCode}
+	}
+
+	logger.Debug("delivered-transition")
+	return nil
+}
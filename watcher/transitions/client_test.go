@@ -0,0 +1,67 @@
+package transitions_test
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server     *ghttp.Server
+		client     transitions.Client
+		transition transitions.Transition
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		client = transitions.NewClient(server.URL()+"/transitions", false)
+
+		transition = transitions.Transition{
+			Event:       transitions.Changed,
+			ProcessGuid: "process-guid",
+			Domain:      cc_messages.AppLRPDomain,
+			Index:       1,
+			From:        cc_messages.LRPInstanceStateStarting,
+			To:          cc_messages.LRPInstanceStateDown,
+			Since:       2,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("posts the transition as JSON", func() {
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/transitions"),
+			ghttp.VerifyContentType("application/json"),
+			ghttp.VerifyJSON(`{
+				"event": "changed",
+				"process_guid": "process-guid",
+				"domain": "cf-apps",
+				"index": 1,
+				"from": "STARTING",
+				"to": "DOWN",
+				"since": 2
+			}`),
+			ghttp.RespondWith(http.StatusAccepted, nil),
+		))
+
+		Expect(client.Transitioned(transition, lagertest.NewTestLogger("test"))).To(Succeed())
+		Expect(server.ReceivedRequests()).To(HaveLen(1))
+	})
+
+	It("fails on a non-2xx response", func() {
+		server.AppendHandlers(ghttp.RespondWith(http.StatusInternalServerError, nil))
+
+		err := client.Transitioned(transition, lagertest.NewTestLogger("test"))
+		Expect(err).To(Equal(&transitions.BadResponseError{StatusCode: http.StatusInternalServerError}))
+	})
+})
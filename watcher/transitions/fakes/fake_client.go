@@ -0,0 +1,56 @@
+// This file was generated by counterfeiter
+package fakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+)
+
+type FakeClient struct {
+	TransitionedStub        func(transition transitions.Transition, logger lager.Logger) error
+	transitionedMutex       sync.RWMutex
+	transitionedArgsForCall []struct {
+		transition transitions.Transition
+		logger     lager.Logger
+	}
+	transitionedReturns struct {
+		result1 error
+	}
+}
+
+func (fake *FakeClient) Transitioned(transition transitions.Transition, logger lager.Logger) error {
+	fake.transitionedMutex.Lock()
+	fake.transitionedArgsForCall = append(fake.transitionedArgsForCall, struct {
+		transition transitions.Transition
+		logger     lager.Logger
+	}{transition, logger})
+	fake.transitionedMutex.Unlock()
+	if fake.TransitionedStub != nil {
+		return fake.TransitionedStub(transition, logger)
+	} else {
+		return fake.transitionedReturns.result1
+	}
+}
+
+func (fake *FakeClient) TransitionedCallCount() int {
+	fake.transitionedMutex.RLock()
+	defer fake.transitionedMutex.RUnlock()
+	return len(fake.transitionedArgsForCall)
+}
+
+func (fake *FakeClient) TransitionedArgsForCall(i int) (transitions.Transition, lager.Logger) {
+	fake.transitionedMutex.RLock()
+	defer fake.transitionedMutex.RUnlock()
+	return fake.transitionedArgsForCall[i].transition, fake.transitionedArgsForCall[i].logger
+}
+
+func (fake *FakeClient) TransitionedReturns(result1 error) {
+	fake.TransitionedStub = nil
+	fake.transitionedReturns = struct {
+		result1 error
+	}{result1}
+}
+
+var _ transitions.Client = new(FakeClient)
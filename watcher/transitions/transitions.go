@@ -0,0 +1,90 @@
+package transitions
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/handler/cc_conv"
+)
+
+const (
+	Created = "created"
+	Changed = "changed"
+	Removed = "removed"
+)
+
+// Transition describes an app instance moving between the states Cloud
+// Controller shows to users. From is empty for a created instance and To is
+// empty for a removed one.
+type Transition struct {
+	Event          string                       `json:"event"`
+	ProcessGuid    string                       `json:"process_guid"`
+	Domain         string                       `json:"domain"`
+	InstanceGuid   string                       `json:"instance_guid,omitempty"`
+	Index          int                          `json:"index"`
+	From           cc_messages.LRPInstanceState `json:"from,omitempty"`
+	To             cc_messages.LRPInstanceState `json:"to,omitempty"`
+	PlacementError string                       `json:"placement_error,omitempty"`
+	Since          int64                        `json:"since"`
+}
+
+// FromEvent converts an actual LRP event into a transition. It returns false
+// for other events, and for changes that do not move the instance to a
+// different state, such as UNCLAIMED to CLAIMED which are both STARTING.
+func FromEvent(event models.Event) (Transition, bool) {
+	switch event := event.(type) {
+	case *models.ActualLRPCreatedEvent:
+		after := resolve(event.ActualLrpGroup)
+		if after == nil {
+			return Transition{}, false
+		}
+		return newTransition(Created, after, "", stateOf(after)), true
+
+	case *models.ActualLRPChangedEvent:
+		before, after := resolve(event.Before), resolve(event.After)
+		if before == nil || after == nil {
+			return Transition{}, false
+		}
+
+		from, to := stateOf(before), stateOf(after)
+		if from == to {
+			return Transition{}, false
+		}
+		return newTransition(Changed, after, from, to), true
+
+	case *models.ActualLRPRemovedEvent:
+		before := resolve(event.ActualLrpGroup)
+		if before == nil {
+			return Transition{}, false
+		}
+		return newTransition(Removed, before, stateOf(before), ""), true
+	}
+
+	return Transition{}, false
+}
+
+func newTransition(event string, actual *models.ActualLRP, from, to cc_messages.LRPInstanceState) Transition {
+	return Transition{
+		Event:          event,
+		ProcessGuid:    actual.ProcessGuid,
+		Domain:         actual.Domain,
+		InstanceGuid:   actual.InstanceGuid,
+		Index:          int(actual.Index),
+		From:           from,
+		To:             to,
+		PlacementError: actual.PlacementError,
+		Since:          actual.Since,
+	}
+}
+
+func resolve(group *models.ActualLRPGroup) *models.ActualLRP {
+	if group == nil || (group.Instance == nil && group.Evacuating == nil) {
+		return nil
+	}
+
+	actual, _ := group.Resolve()
+	return actual
+}
+
+func stateOf(actual *models.ActualLRP) cc_messages.LRPInstanceState {
+	return cc_conv.StateFor(actual.State, actual.PlacementError)
+}
@@ -0,0 +1,13 @@
+package transitions_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTransitions(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Transitions Suite")
+}
@@ -0,0 +1,99 @@
+package transitions_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/models/test/model_helpers"
+	"code.cloudfoundry.org/runtimeschema/cc_messages"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("FromEvent", func() {
+	var before, after *models.ActualLRP
+
+	BeforeEach(func() {
+		before = model_helpers.NewValidActualLRP("process-guid", 1)
+		before.Domain = cc_messages.AppLRPDomain
+		before.State = models.ActualLRPStateClaimed
+		before.InstanceGuid = "instance-guid"
+		before.Since = 1
+
+		after = model_helpers.NewValidActualLRP("process-guid", 1)
+		after.Domain = cc_messages.AppLRPDomain
+		after.State = models.ActualLRPStateRunning
+		after.InstanceGuid = "instance-guid"
+		after.Since = 2
+	})
+
+	It("converts a change of state", func() {
+		transition, ok := transitions.FromEvent(models.NewActualLRPChangedEvent(
+			&models.ActualLRPGroup{Instance: before},
+			&models.ActualLRPGroup{Instance: after},
+		))
+		Expect(ok).To(BeTrue())
+		Expect(transition).To(Equal(transitions.Transition{
+			Event:        transitions.Changed,
+			ProcessGuid:  "process-guid",
+			Domain:       cc_messages.AppLRPDomain,
+			InstanceGuid: "instance-guid",
+			Index:        1,
+			From:         cc_messages.LRPInstanceStateStarting,
+			To:           cc_messages.LRPInstanceStateRunning,
+			Since:        2,
+		}))
+	})
+
+	It("converts an instance becoming unplaceable", func() {
+		before.State = models.ActualLRPStateUnclaimed
+		after.State = models.ActualLRPStateUnclaimed
+		after.PlacementError = "insufficient resources"
+
+		transition, ok := transitions.FromEvent(models.NewActualLRPChangedEvent(
+			&models.ActualLRPGroup{Instance: before},
+			&models.ActualLRPGroup{Instance: after},
+		))
+		Expect(ok).To(BeTrue())
+		Expect(transition.From).To(Equal(cc_messages.LRPInstanceStateStarting))
+		Expect(transition.To).To(Equal(cc_messages.LRPInstanceStateDown))
+		Expect(transition.PlacementError).To(Equal("insufficient resources"))
+	})
+
+	It("ignores changes within the same state", func() {
+		before.State = models.ActualLRPStateUnclaimed
+		after.State = models.ActualLRPStateClaimed
+
+		_, ok := transitions.FromEvent(models.NewActualLRPChangedEvent(
+			&models.ActualLRPGroup{Instance: before},
+			&models.ActualLRPGroup{Instance: after},
+		))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("converts a created instance", func() {
+		transition, ok := transitions.FromEvent(models.NewActualLRPCreatedEvent(&models.ActualLRPGroup{Instance: after}))
+		Expect(ok).To(BeTrue())
+		Expect(transition.Event).To(Equal(transitions.Created))
+		Expect(transition.From).To(BeEmpty())
+		Expect(transition.To).To(Equal(cc_messages.LRPInstanceStateRunning))
+	})
+
+	It("converts a removed instance", func() {
+		transition, ok := transitions.FromEvent(models.NewActualLRPRemovedEvent(&models.ActualLRPGroup{Instance: before}))
+		Expect(ok).To(BeTrue())
+		Expect(transition.Event).To(Equal(transitions.Removed))
+		Expect(transition.From).To(Equal(cc_messages.LRPInstanceStateStarting))
+		Expect(transition.To).To(BeEmpty())
+	})
+
+	It("ignores events without an actual LRP", func() {
+		_, ok := transitions.FromEvent(&models.ActualLRPCreatedEvent{})
+		Expect(ok).To(BeFalse())
+	})
+
+	It("ignores other events", func() {
+		_, ok := transitions.FromEvent(models.NewActualLRPCrashedEvent(before))
+		Expect(ok).To(BeFalse())
+	})
+})
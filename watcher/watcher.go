@@ -7,29 +7,40 @@ import (
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/bbs/events"
 	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/runtimeschema/cc_messages"
-	"code.cloudfoundry.org/tps/cc_client"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+	"code.cloudfoundry.org/tps/watcher/transitions"
 	"code.cloudfoundry.org/workpool"
 )
 
-const DefaultRetryPauseInterval = time.Second
+const (
+	DefaultRetryPauseInterval = time.Second
+	DefaultReconcileLookback  = time.Minute
+)
 
 type Watcher struct {
 	bbsClient          bbs.Client
-	ccClient           cc_client.CcClient
+	domainRoutes       DomainRoutes
+	notifier           sinks.Notifier
+	clock              clock.Clock
 	logger             lager.Logger
 	retryPauseInterval time.Duration
 
-	pool *workpool.WorkPool
+	pool    *workpool.WorkPool
+	crashes *crashRecord
 }
 
 func NewWatcher(
 	logger lager.Logger,
 	workPoolSize int,
 	retryPauseInterval time.Duration,
+	reconcileLookback time.Duration,
+	domainRoutes DomainRoutes,
 	bbsClient bbs.Client,
-	ccClient cc_client.CcClient,
+	notifier sinks.Notifier,
+	clock clock.Clock,
 ) (*Watcher, error) {
 	workPool, err := workpool.NewWorkPool(workPoolSize)
 	if err != nil {
@@ -38,10 +49,13 @@ func NewWatcher(
 
 	return &Watcher{
 		bbsClient:          bbsClient,
-		ccClient:           ccClient,
+		domainRoutes:       domainRoutes,
+		notifier:           notifier,
+		clock:              clock,
 		logger:             logger,
 		retryPauseInterval: retryPauseInterval,
 		pool:               workPool,
+		crashes:            newCrashRecord(clock, clock.Now().Add(-reconcileLookback)),
 	}, nil
 }
 
@@ -66,6 +80,7 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 		case subscription = <-subscriptionChan:
 			if subscription != nil {
 				go nextEvent(logger, subscription, eventChan, errorChan, watcher.retryPauseInterval)
+				go watcher.reconcile(logger)
 			} else {
 				go subscribeToEvents(logger, watcher.bbsClient, subscriptionChan)
 			}
@@ -107,35 +122,116 @@ func (watcher *Watcher) Run(signals <-chan os.Signal, ready chan<- struct{}) err
 
 func (watcher *Watcher) handleEvent(logger lager.Logger, event models.Event) {
 	if crashed, ok := event.(*models.ActualLRPCrashedEvent); ok {
-		if crashed.ActualLRPKey.Domain == cc_messages.AppLRPDomain {
+		if route, ok := watcher.domainRoutes.Match(crashed.ActualLRPKey.Domain); ok {
 			logger.Info("app-crashed", lager.Data{
 				"process-guid": crashed.ActualLRPKey.ProcessGuid,
 				"index":        crashed.ActualLRPKey.Index,
+				"domain":       crashed.ActualLRPKey.Domain,
 			})
 
-			guid := crashed.ActualLRPKey.ProcessGuid
-			appCrashed := cc_messages.AppCrashedRequest{
+			watcher.crashes.record(crashed.ActualLRPKey, crashed.CrashCount, crashed.Since)
+			watcher.reportCrash(logger, route, crashed.ActualLRPKey, cc_messages.AppCrashedRequest{
 				Instance:        crashed.ActualLRPInstanceKey.InstanceGuid,
 				Index:           int(crashed.ActualLRPKey.Index),
-				Reason:          "CRASHED",
+				Reason:          route.Reason,
 				ExitDescription: crashed.CrashReason,
 				CrashCount:      int(crashed.CrashCount),
 				CrashTimestamp:  crashed.Since,
+			})
+		}
+		return
+	}
+
+	if watcher.notifier.Wants(sinks.TransitionKind) {
+		if transition, ok := transitions.FromEvent(event); ok {
+			if route, ok := watcher.domainRoutes.Match(transition.Domain); ok {
+				watcher.reportTransition(logger, route, transition)
 			}
+		}
+	}
+}
 
-			watcher.pool.Submit(func() {
-				logger := logger.WithData(lager.Data{
-					"process-guid": guid,
-					"index":        appCrashed.Index,
-				})
-				logger.Info("recording-app-crashed")
-				err := watcher.ccClient.AppCrashed(guid, appCrashed, logger)
-				if err != nil {
-					logger.Error("failed-recording-app-crashed", err)
-				}
-			})
+// reconcile reports crashes that happened while the watcher was not
+// subscribed to BBS events, by comparing the crash count and timestamp of
+// every watched instance against what was last reported for it.
+func (watcher *Watcher) reconcile(logger lager.Logger) {
+	logger = logger.Session("reconcile")
+	logger.Info("starting")
+	defer logger.Info("finished")
+
+	startedAt := watcher.clock.Now()
+	actualLRPs := []*models.ActualLRP{}
+
+	for _, domain := range watcher.domainRoutes.domainFilters() {
+		groups, err := watcher.bbsClient.ActualLRPGroups(logger, models.ActualLRPFilter{Domain: domain})
+		if err != nil {
+			logger.Error("failed-fetching-actual-lrps", err, lager.Data{"domain": domain})
+			return
+		}
+
+		for _, group := range groups {
+			if group == nil || (group.Instance == nil && group.Evacuating == nil) {
+				continue
+			}
+
+			actualLRP, _ := group.Resolve()
+
+			if _, ok := watcher.domainRoutes.Match(actualLRP.Domain); ok {
+				actualLRPs = append(actualLRPs, actualLRP)
+			}
 		}
 	}
+
+	missed := watcher.crashes.reconcile(actualLRPs, startedAt)
+	logger.Info("found-missed-crashes", lager.Data{"count": len(missed)})
+
+	for _, actualLRP := range missed {
+		route, _ := watcher.domainRoutes.Match(actualLRP.Domain)
+		watcher.reportCrash(logger, route, actualLRP.ActualLRPKey, cc_messages.AppCrashedRequest{
+			Instance:        actualLRP.InstanceGuid,
+			Index:           int(actualLRP.Index),
+			Reason:          route.Reason,
+			ExitDescription: actualLRP.CrashReason,
+			CrashCount:      int(actualLRP.CrashCount),
+			CrashTimestamp:  actualLRP.Since,
+		})
+	}
+}
+
+func (watcher *Watcher) reportCrash(logger lager.Logger, route DomainRoute, key models.ActualLRPKey, appCrashed cc_messages.AppCrashedRequest) {
+	watcher.pool.Submit(func() {
+		logger := logger.WithData(lager.Data{
+			"process-guid": key.ProcessGuid,
+			"index":        appCrashed.Index,
+		})
+		logger.Info("recording-app-crashed")
+		watcher.notifier.Notify(logger, sinks.Notification{
+			Kind:        sinks.CrashKind,
+			ProcessGuid: key.ProcessGuid,
+			Domain:      key.Domain,
+			Targets:     route.Sinks,
+			Crash:       &appCrashed,
+		})
+	})
+}
+
+func (watcher *Watcher) reportTransition(logger lager.Logger, route DomainRoute, transition transitions.Transition) {
+	watcher.pool.Submit(func() {
+		logger := logger.WithData(lager.Data{
+			"process-guid": transition.ProcessGuid,
+			"index":        transition.Index,
+			"from":         transition.From,
+			"to":           transition.To,
+		})
+		logger.Info("recording-transition")
+		watcher.notifier.Notify(logger, sinks.Notification{
+			Kind:        sinks.TransitionKind,
+			ProcessGuid: transition.ProcessGuid,
+			Domain:      transition.Domain,
+			Targets:     route.Sinks,
+			Transition:  &transition,
+		})
+	})
 }
 
 func subscribeToEvents(logger lager.Logger, bbsClient bbs.Client, subscriptionChan chan<- events.EventSource) {
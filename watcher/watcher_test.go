@@ -11,11 +11,17 @@ import (
 	"code.cloudfoundry.org/bbs/fake_bbs"
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/bbs/models/test/model_helpers"
+	"code.cloudfoundry.org/clock/fakeclock"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/runtimeschema/cc_messages"
 	"code.cloudfoundry.org/tps/cc_client/fakes"
 	"code.cloudfoundry.org/tps/watcher"
+	retryqueuefakes "code.cloudfoundry.org/tps/watcher/retryqueue/fakes"
+	"code.cloudfoundry.org/tps/watcher/sinks"
+	sinkfakes "code.cloudfoundry.org/tps/watcher/sinks/fakes"
+	"code.cloudfoundry.org/tps/watcher/transitions"
+	transitionfakes "code.cloudfoundry.org/tps/watcher/transitions/fakes"
 	"github.com/tedsuo/ifrit"
 
 	. "github.com/onsi/ginkgo"
@@ -31,11 +37,17 @@ var nilEventHolder = EventHolder{}
 
 var _ = Describe("Watcher", func() {
 	var (
-		eventSource   *eventfakes.FakeEventSource
-		bbsClient     *fake_bbs.FakeInternalClient
-		ccClient      *fakes.FakeCcClient
-		watcherRunner *watcher.Watcher
-		process       ifrit.Process
+		eventSource      *eventfakes.FakeEventSource
+		bbsClient        *fake_bbs.FakeInternalClient
+		ccClient         *fakes.FakeCcClient
+		retryQueue       *retryqueuefakes.FakeQueue
+		transitionClient *transitionfakes.FakeClient
+		sreSink          *sinkfakes.FakeSink
+		domainRoutes     watcher.DomainRoutes
+		fakeClock        *fakeclock.FakeClock
+		watcherRunner    *watcher.Watcher
+		process          ifrit.Process
+		fanoutProcess    ifrit.Process
 
 		logger *lagertest.TestLogger
 
@@ -50,10 +62,11 @@ var _ = Describe("Watcher", func() {
 
 		logger = lagertest.NewTestLogger("test")
 		ccClient = new(fakes.FakeCcClient)
-
-		var err error
-		watcherRunner, err = watcher.NewWatcher(logger, 500, 10*time.Millisecond, bbsClient, ccClient)
-		Expect(err).NotTo(HaveOccurred())
+		retryQueue = new(retryqueuefakes.FakeQueue)
+		transitionClient = new(transitionfakes.FakeClient)
+		sreSink = new(sinkfakes.FakeSink)
+		domainRoutes = watcher.DefaultDomainRoutes
+		fakeClock = fakeclock.NewFakeClock(time.Now())
 
 		nextErr = atomic.Value{}
 		nextErr := nextErr
@@ -84,12 +97,33 @@ var _ = Describe("Watcher", func() {
 	})
 
 	JustBeforeEach(func() {
+		targets := []sinks.Target{
+			{Name: sinks.CCSinkName, Sink: sinks.NewCCSink(ccClient, retryQueue), Kinds: []string{sinks.CrashKind}},
+			{Name: "sre", Sink: sreSink, Kinds: []string{sinks.CrashKind}},
+		}
+		if transitionClient != nil {
+			targets = append(targets, sinks.Target{
+				Name:  sinks.TransitionSinkName,
+				Sink:  sinks.NewTransitionSink(transitionClient),
+				Kinds: []string{sinks.TransitionKind},
+			})
+		}
+
+		fanout := sinks.NewFanout(targets...)
+		fanoutProcess = ifrit.Invoke(fanout)
+
+		var err error
+		watcherRunner, err = watcher.NewWatcher(logger, 500, 10*time.Millisecond, time.Minute, domainRoutes, bbsClient, fanout, fakeClock)
+		Expect(err).NotTo(HaveOccurred())
+
 		process = ifrit.Invoke(watcherRunner)
 	})
 
 	AfterEach(func() {
 		process.Signal(os.Interrupt)
 		Eventually(process.Wait()).Should(Receive())
+		fanoutProcess.Signal(os.Interrupt)
+		Eventually(fanoutProcess.Wait()).Should(Receive())
 	})
 
 	Describe("Actual LRP crashes", func() {
@@ -118,6 +152,21 @@ var _ = Describe("Watcher", func() {
 				}))
 
 				Expect(logger).To(Say("app-crashed"))
+				Consistently(retryQueue.EnqueueCallCount).Should(Equal(0))
+			})
+
+			Context("when Cloud Controller fails to record the crash", func() {
+				BeforeEach(func() {
+					ccClient.AppCrashedReturns(errors.New("cc is down"))
+				})
+
+				It("enqueues the crash report for retry", func() {
+					Eventually(retryQueue.EnqueueCallCount).Should(Equal(1))
+					_, guid, crashed := retryQueue.EnqueueArgsForCall(0)
+					Expect(guid).To(Equal("process-guid"))
+					Expect(crashed.Instance).To(Equal("instance-guid"))
+					Expect(crashed.CrashCount).To(Equal(1))
+				})
 			})
 		})
 
@@ -151,6 +200,236 @@ var _ = Describe("Watcher", func() {
 		})
 	})
 
+	Describe("Domain routes", func() {
+		var platformActual *models.ActualLRP
+
+		BeforeEach(func() {
+			domainRoutes = watcher.DomainRoutes{
+				{Domain: "platform-*", Reason: "PLATFORM_CRASHED", Sinks: []string{"sre"}},
+				{Domain: cc_messages.AppLRPDomain, Reason: watcher.DefaultCrashReason, Sinks: []string{sinks.CCSinkName}},
+			}
+
+			platformActual = makeActualLRP("router-guid", "instance-guid", 0, 3, 1, "platform-routing", "exited")
+			nextEvent.Store(EventHolder{models.NewActualLRPCrashedEvent(platformActual)})
+		})
+
+		It("reports crashes in matching domains to their sinks with their reason", func() {
+			Eventually(sreSink.SendCallCount).Should(Equal(1))
+			_, notification := sreSink.SendArgsForCall(0)
+			Expect(notification.ProcessGuid).To(Equal("router-guid"))
+			Expect(notification.Domain).To(Equal("platform-routing"))
+			Expect(notification.Crash.Reason).To(Equal("PLATFORM_CRASHED"))
+
+			Consistently(ccClient.AppCrashedCallCount).Should(Equal(0))
+		})
+
+		It("lists every domain when reconciling with a pattern", func() {
+			Eventually(bbsClient.ActualLRPGroupsCallCount).Should(BeNumerically(">=", 1))
+			_, filter := bbsClient.ActualLRPGroupsArgsForCall(0)
+			Expect(filter.Domain).To(BeEmpty())
+		})
+
+		Context("with the default routes", func() {
+			BeforeEach(func() {
+				domainRoutes = watcher.DefaultDomainRoutes
+			})
+
+			It("ignores crashes outside the cc-app Domain", func() {
+				Consistently(sreSink.SendCallCount).Should(Equal(0))
+				Expect(ccClient.AppCrashedCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("Reconciliation", func() {
+		var missed, restarted *models.ActualLRP
+
+		BeforeEach(func() {
+			missed = makeActualLRP("missed-process-guid", "instance-guid", 2, 0, 4, cc_messages.AppLRPDomain, "killed")
+			missed.State = models.ActualLRPStateCrashed
+			missed.Since = fakeClock.Now().UnixNano()
+
+			old := makeActualLRP("old-process-guid", "instance-guid", 0, 0, 1, cc_messages.AppLRPDomain, "killed")
+			old.State = models.ActualLRPStateCrashed
+
+			healthy := makeActualLRP("healthy-process-guid", "instance-guid", 0, 0, 0, cc_messages.AppLRPDomain, "")
+			healthy.Since = fakeClock.Now().UnixNano()
+
+			restarted = makeActualLRP("restarted-process-guid", "instance-guid", 0, 0, 2, cc_messages.AppLRPDomain, "killed")
+			restarted.Since = fakeClock.Now().UnixNano()
+
+			bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{
+				{Instance: missed},
+				{Instance: old},
+				{Instance: healthy},
+				{},
+				{Instance: restarted},
+			}, nil)
+		})
+
+		It("reports crashes that happened while the watcher was not subscribed", func() {
+			Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+			Consistently(ccClient.AppCrashedCallCount).Should(Equal(1))
+
+			_, filter := bbsClient.ActualLRPGroupsArgsForCall(0)
+			Expect(filter.Domain).To(Equal(cc_messages.AppLRPDomain))
+
+			guid, crashed, _ := ccClient.AppCrashedArgsForCall(0)
+			Expect(guid).To(Equal("missed-process-guid"))
+			Expect(crashed).To(Equal(cc_messages.AppCrashedRequest{
+				Instance:        "instance-guid",
+				Index:           2,
+				Reason:          "CRASHED",
+				ExitDescription: "killed",
+				CrashCount:      4,
+				CrashTimestamp:  missed.Since,
+			}))
+		})
+
+		It("does not report instances that only restarted within the lookback", func() {
+			Eventually(bbsClient.ActualLRPGroupsCallCount).Should(Equal(1))
+			Consistently(ccClient.AppCrashedCallCount).Should(BeNumerically("<=", 1))
+
+			for i := 0; i < ccClient.AppCrashedCallCount(); i++ {
+				guid, _, _ := ccClient.AppCrashedArgsForCall(i)
+				Expect(guid).NotTo(Equal("restarted-process-guid"))
+			}
+		})
+
+		Context("when the watcher resubscribes", func() {
+			BeforeEach(func() {
+				eventSource.NextStub = func() (models.Event, error) {
+					return nil, errors.New("next-error")
+				}
+			})
+
+			It("reconciles again without reporting the same crash twice", func() {
+				Eventually(bbsClient.ActualLRPGroupsCallCount, 5*time.Second).Should(BeNumerically(">", 1))
+				Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+				Consistently(ccClient.AppCrashedCallCount).Should(Equal(1))
+			})
+
+			Context("and the instance crashed again in the meantime", func() {
+				It("reports the new crash", func() {
+					Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+
+					crashedAgain := *missed
+					crashedAgain.CrashCount = 5
+					bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{{Instance: &crashedAgain}}, nil)
+
+					Eventually(ccClient.AppCrashedCallCount, 5*time.Second).Should(Equal(2))
+					_, crashed, _ := ccClient.AppCrashedArgsForCall(1)
+					Expect(crashed.CrashCount).To(Equal(5))
+				})
+			})
+
+			Context("and a restarted instance crashed again and awaits its restart", func() {
+				It("reports the new crash as of when it happened", func() {
+					Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+
+					crashedAgain := *restarted
+					crashedAgain.State = models.ActualLRPStateUnclaimed
+					crashedAgain.InstanceGuid = ""
+					crashedAgain.CrashCount = 3
+					crashedAgain.Since = restarted.Since + int64(time.Second)
+					bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{{Instance: missed}, {Instance: &crashedAgain}}, nil)
+
+					Eventually(ccClient.AppCrashedCallCount, 5*time.Second).Should(Equal(2))
+					guid, crashed, _ := ccClient.AppCrashedArgsForCall(1)
+					Expect(guid).To(Equal("restarted-process-guid"))
+					Expect(crashed.Instance).To(BeEmpty())
+					Expect(crashed.CrashCount).To(Equal(3))
+					Expect(crashed.CrashTimestamp).To(Equal(crashedAgain.Since))
+				})
+			})
+
+			Context("and a restarted instance crashed and was placed again in the meantime", func() {
+				It("does not report the new instance as crashed", func() {
+					Eventually(ccClient.AppCrashedCallCount).Should(Equal(1))
+
+					placedAgain := *restarted
+					placedAgain.InstanceGuid = "new-instance-guid"
+					placedAgain.CrashCount = 3
+					placedAgain.Since = restarted.Since + int64(time.Minute)
+					bbsClient.ActualLRPGroupsReturns([]*models.ActualLRPGroup{{Instance: missed}, {Instance: &placedAgain}}, nil)
+					calls := bbsClient.ActualLRPGroupsCallCount()
+
+					Eventually(bbsClient.ActualLRPGroupsCallCount, 5*time.Second).Should(BeNumerically(">", calls))
+					Consistently(ccClient.AppCrashedCallCount).Should(Equal(1))
+				})
+			})
+		})
+
+		Context("when the crash was already received as an event", func() {
+			BeforeEach(func() {
+				nextEvent.Store(EventHolder{models.NewActualLRPCrashedEvent(missed)})
+				bbsClient.ActualLRPGroupsStub = func(lager.Logger, models.ActualLRPFilter) ([]*models.ActualLRPGroup, error) {
+					for deadline := time.Now().Add(time.Second); ccClient.AppCrashedCallCount() == 0 && time.Now().Before(deadline); {
+						time.Sleep(10 * time.Millisecond)
+					}
+					return []*models.ActualLRPGroup{{Instance: missed}}, nil
+				}
+			})
+
+			It("does not report it again", func() {
+				Eventually(bbsClient.ActualLRPGroupsCallCount).Should(Equal(1))
+				Consistently(ccClient.AppCrashedCallCount).Should(Equal(1))
+			})
+		})
+	})
+
+	Describe("State transitions", func() {
+		var before, after *models.ActualLRP
+
+		BeforeEach(func() {
+			before = makeActualLRP("process-guid", "instance-guid", 1, 3, 0, cc_messages.AppLRPDomain, "")
+			before.State = models.ActualLRPStateUnclaimed
+			after = makeActualLRP("process-guid", "instance-guid", 1, 4, 0, cc_messages.AppLRPDomain, "")
+			after.State = models.ActualLRPStateUnclaimed
+			after.PlacementError = "insufficient resources"
+		})
+
+		JustBeforeEach(func() {
+			nextEvent.Store(EventHolder{models.NewActualLRPChangedEvent(
+				&models.ActualLRPGroup{Instance: before},
+				&models.ActualLRPGroup{Instance: after},
+			)})
+		})
+
+		It("reports the transition", func() {
+			Eventually(transitionClient.TransitionedCallCount).Should(Equal(1))
+			transition, _ := transitionClient.TransitionedArgsForCall(0)
+			Expect(transition.Event).To(Equal(transitions.Changed))
+			Expect(transition.ProcessGuid).To(Equal("process-guid"))
+			Expect(transition.From).To(Equal(cc_messages.LRPInstanceStateStarting))
+			Expect(transition.To).To(Equal(cc_messages.LRPInstanceStateDown))
+			Expect(transition.PlacementError).To(Equal("insufficient resources"))
+
+			Expect(ccClient.AppCrashedCallCount()).To(Equal(0))
+		})
+
+		Context("when the instance is not in the cc-app Domain", func() {
+			BeforeEach(func() {
+				before.Domain = "other-domain"
+				after.Domain = "other-domain"
+			})
+
+			It("does not report the transition", func() {
+				Consistently(transitionClient.TransitionedCallCount).Should(Equal(0))
+			})
+		})
+
+		Context("when transition reporting is disabled", func() {
+			BeforeEach(func() {
+				transitionClient = nil
+			})
+
+			It("ignores the event", func() {
+				Consistently(ccClient.AppCrashedCallCount).Should(Equal(0))
+			})
+		})
+	})
+
 	Describe("Unrecognized events", func() {
 		Context("when its not ActualLRPCrashed event", func() {
 